@@ -0,0 +1,85 @@
+package smallapi
+
+import (
+	"strconv"
+	"strings"
+)
+
+// mediaRange is one entry of an Accept header, such as "text/html;q=0.8"
+type mediaRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// parseAccept parses an Accept header into its media ranges
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok {
+			if typ != "*" {
+				continue
+			}
+			subtype = "*" // a bare "*" is sent by some clients
+		}
+
+		r := mediaRange{typ: typ, subtype: subtype, q: 1}
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q >= 0 && q <= 1 {
+					r.q = q
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// quality returns the q-value the ranges give mediaType, taken from the most
+// specific matching range, or -1 if no range matches
+func quality(ranges []mediaRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(strings.ToLower(mediaType), "/")
+
+	q, specificity := -1.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// negotiateType returns the offered media type the Accept header prefers.
+// Offers are listed in the server's order of preference, which breaks
+// ties; a missing header accepts the first offer. It returns "" when the
+// header accepts none of the offers.
+func negotiateType(header string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	ranges := parseAccept(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := quality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,109 @@
+package smallapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// mountMethods are the methods routed to a mounted handler
+var mountMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// Mount serves every request below prefix with an existing http.Handler,
+// such as net/http/pprof, a legacy mux or another *App. The prefix is
+// stripped from the request path before the handler sees it.
+//
+//	app.Mount("/debug/pprof", http.HandlerFunc(pprof.Index))
+//	app.Mount("/legacy", legacyMux)
+func (a *App) Mount(prefix string, handler http.Handler) *App {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	serve := func(c *Context) {
+		r := c.Request.Clone(c.Request.Context())
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+		if r.URL.Path == "" {
+			r.URL.Path = "/"
+		}
+		if r.URL.RawPath != "" {
+			r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+		}
+
+		handler.ServeHTTP(c.Response, r)
+	}
+
+	return a.Route(mountMethods, prefix+"/*", serve)
+}
+
+// Wrap wraps the whole application in standard net/http middleware. The
+// first middleware wrapped is the outermost one, so it sees the request
+// first and the response last.
+//
+//	app.Wrap(handlers.CompressHandler)
+func (a *App) Wrap(middleware func(http.Handler) http.Handler) *App {
+	a.wrappers = append(a.wrappers, middleware)
+
+	var handler http.Handler = http.HandlerFunc(a.serve)
+	for i := len(a.wrappers) - 1; i >= 0; i-- {
+		handler = a.wrappers[i](handler)
+	}
+	a.handler = handler
+
+	return a
+}
+
+// HTTPHandler converts a smallapi handler into a standard http.Handler
+// that can be used with any router or server
+func (a *App) HTTPHandler(handler HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := NewContext(w, r, a, a.sessions)
+		ctx.handlers = []MiddlewareFunc{MiddlewareFunc(handler)}
+		ctx.Next()
+	})
+}
+
+// HTTPMiddleware converts a smallapi middleware into standard net/http
+// middleware. The next handler runs when the middleware calls c.Next().
+func (a *App) HTTPMiddleware(middleware MiddlewareFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := NewContext(w, r, a, a.sessions)
+			ctx.handlers = []MiddlewareFunc{middleware, func(c *Context) {
+				next.ServeHTTP(c.Response, c.Request)
+			}}
+			ctx.Next()
+		})
+	}
+}
+
+// WrapHandler converts a standard http.Handler into a smallapi handler
+//
+//	app.Get("/metrics", smallapi.WrapHandler(promhttp.Handler()))
+func WrapHandler(handler http.Handler) HandlerFunc {
+	return func(c *Context) {
+		handler.ServeHTTP(c.Response, c.Request)
+	}
+}
+
+// WrapHandlerFunc converts a standard http.HandlerFunc into a smallapi handler
+func WrapHandlerFunc(handler http.HandlerFunc) HandlerFunc {
+	return WrapHandler(handler)
+}
+
+// WrapMiddleware converts standard net/http middleware into a smallapi
+// middleware, for use with App.Use, RouteGroup.Use or Blueprint.Use. The
+// rest of the chain runs when the middleware calls its next handler, with
+// the request and response writer it passes on; work it does after next
+// returns sees the response, as it would in net/http.
+func WrapMiddleware(middleware func(http.Handler) http.Handler) MiddlewareFunc {
+	return func(c *Context) {
+		request, response := c.Request, c.Response
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Response = w
+			c.Request = r
+			c.Next()
+			c.Response = response
+			c.Request = request
+		})
+
+		middleware(next).ServeHTTP(c.Response, c.Request)
+	}
+}
@@ -98,6 +98,43 @@ func (am *AuthManager) Logout(token string) {
 	delete(am.sessions, token)
 }
 
+// LogoutAll removes every session token of a user
+func (am *AuthManager) LogoutAll(userID string) {
+	for token, user := range am.sessions {
+		if user.ID == userID {
+			delete(am.sessions, token)
+		}
+	}
+}
+
+// LoginSession logs a user into the request's session. The session is
+// moved to a new ID first, so an ID planted before login cannot be used
+// to take it over.
+func (am *AuthManager) LoginSession(c *Context, username, password string) (*User, error) {
+	token, user, err := am.Login(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	session := c.Session()
+	if err := session.Regenerate(); err != nil {
+		am.Logout(token)
+		return nil, err
+	}
+	session.Set("auth_token", token)
+	session.Set(SessionUserKey, user.ID)
+	return user, nil
+}
+
+// LogoutSession logs the user of the request's session out and destroys
+// the session
+func (am *AuthManager) LogoutSession(c *Context) error {
+	if token, ok := c.Session().Get("auth_token").(string); ok {
+		am.Logout(token)
+	}
+	return c.Session().Destroy()
+}
+
 // GetUser returns a user by session token
 func (am *AuthManager) GetUser(token string) *User {
 	return am.sessions[token]
@@ -147,11 +184,7 @@ func (am *AuthManager) DeleteUser(userID string) error {
 	delete(am.users, userID)
 	
 	// Remove all sessions for this user
-	for token, user := range am.sessions {
-		if user.ID == userID {
-			delete(am.sessions, token)
-		}
-	}
+	am.LogoutAll(userID)
 	
 	return nil
 }
@@ -192,10 +225,11 @@ func verifyPassword(hash, password string) bool {
 
 // Auth returns a middleware that provides authentication
 func Auth(authManager *AuthManager) MiddlewareFunc {
-	return func(c *Context) bool {
+	return func(c *Context) {
 		token := c.Session().Get("auth_token")
 		if token == nil {
-			return true // Continue without authentication
+			c.Next() // Continue without authentication
+			return
 		}
 		
 		user := authManager.GetUser(token.(string))
@@ -204,31 +238,27 @@ func Auth(authManager *AuthManager) MiddlewareFunc {
 			c.Set("user_id", user.ID)
 		}
 		
-		return true
+		c.Next()
 	}
 }
 
 // RequireUser returns a middleware that requires a logged-in user
 func RequireUser(authManager *AuthManager) MiddlewareFunc {
-	return func(c *Context) bool {
+	return func(c *Context) {
 		token := c.Session().Get("auth_token")
 		if token == nil {
-			c.Status(401).JSON(map[string]string{
-				"error": "Authentication required",
-			})
-			return false
+			c.app.renderError(c, c.route, 401, NewHTTPError(401, "Authentication required"))
+			return
 		}
 		
 		user := authManager.GetUser(token.(string))
 		if user == nil {
-			c.Status(401).JSON(map[string]string{
-				"error": "Invalid session",
-			})
-			return false
+			c.app.renderError(c, c.route, 401, NewHTTPError(401, "Invalid session"))
+			return
 		}
 		
 		c.Set("user", user)
 		c.Set("user_id", user.ID)
-		return true
+		c.Next()
 	}
 }
@@ -0,0 +1,408 @@
+package smallapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMultipartMemory is how much of a multipart body is kept in memory
+// while binding, as in net/http
+const defaultMultipartMemory = 32 << 20
+
+// ShouldBind fills v from the request and validates it. The body is decoded
+// according to its Content-Type: JSON (also the default when the header is
+// missing), XML, urlencoded or multipart form. Struct fields tagged with
+// param, query, header or form are then set from the path parameters,
+// query string, headers and form fields, converting the text to the field
+// type; *multipart.FileHeader and []*multipart.FileHeader form fields take
+// the uploaded files. Decoding errors are returned as a 400 *HTTPError, an unsupported
+// Content-Type as 415 and failed validation as ValidationErrors.
+//
+//	type UpdateUser struct {
+//	        ID    int    `param:"id"`
+//	        Name  string `json:"name" validate:"required"`
+//	        Token string `header:"X-Token"`
+//	        Dry   bool   `query:"dry_run"`
+//	}
+func (c *Context) ShouldBind(v interface{}) error {
+	if err := c.DecodeBody(v); err != nil {
+		return err
+	}
+
+	mediaType := c.contentType()
+	isForm := mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+	return c.bindFields(v, isForm)
+}
+
+// DecodeBody decodes the request body into v according to its
+// Content-Type, like ShouldBind, but neither binds tagged fields nor
+// validates. It suits partial updates whose fields are all optional. Form
+// bodies are parsed into the form values rather than into v.
+func (c *Context) DecodeBody(v interface{}) error {
+	if !c.hasBody() {
+		return nil
+	}
+
+	switch mediaType := c.contentType(); {
+	case mediaType == "" || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return c.decodeJSON(v)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return c.decodeXML(v)
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data":
+		return c.parseForm(mediaType)
+	default:
+		return NewHTTPError(http.StatusUnsupportedMediaType, fmt.Sprintf("Unsupported Content-Type %s", mediaType))
+	}
+}
+
+// ShouldBindJSON decodes a JSON body into v, then binds tagged fields and
+// validates like ShouldBind
+func (c *Context) ShouldBindJSON(v interface{}) error {
+	if c.hasBody() {
+		if err := c.decodeJSON(v); err != nil {
+			return err
+		}
+	}
+	return c.bindFields(v, false)
+}
+
+// ShouldBindXML decodes an XML body into v, then binds tagged fields and
+// validates like ShouldBind
+func (c *Context) ShouldBindXML(v interface{}) error {
+	if c.hasBody() {
+		if err := c.decodeXML(v); err != nil {
+			return err
+		}
+	}
+	return c.bindFields(v, false)
+}
+
+// ShouldBindQuery fills v from the query string only, using query tags and
+// otherwise the JSON names of the fields, and validates it
+func (c *Context) ShouldBindQuery(v interface{}) error {
+	return c.bindValues(v, "query", c.query)
+}
+
+// Bind is ShouldBind, aborting the request with the error on failure
+//
+//	var req CreateTask
+//	c.Bind(&req) // answers 400, 415 or 422 and stops the handler if invalid
+func (c *Context) Bind(v interface{}) {
+	if err := c.ShouldBind(v); err != nil {
+		c.AbortWithError(err)
+	}
+}
+
+// BindJSON is ShouldBindJSON, aborting the request with the error on failure
+func (c *Context) BindJSON(v interface{}) {
+	if err := c.ShouldBindJSON(v); err != nil {
+		c.AbortWithError(err)
+	}
+}
+
+// BindXML is ShouldBindXML, aborting the request with the error on failure
+func (c *Context) BindXML(v interface{}) {
+	if err := c.ShouldBindXML(v); err != nil {
+		c.AbortWithError(err)
+	}
+}
+
+// BindQuery is ShouldBindQuery, aborting the request with the error on failure
+func (c *Context) BindQuery(v interface{}) {
+	if err := c.ShouldBindQuery(v); err != nil {
+		c.AbortWithError(err)
+	}
+}
+
+// contentType returns the media type of the request body, without parameters
+func (c *Context) contentType() string {
+	header := c.Request.Header.Get("Content-Type")
+	if header == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(header))
+	}
+	return mediaType
+}
+
+// hasBody reports whether the request may carry a body; chunked bodies
+// have an unknown length
+func (c *Context) hasBody() bool {
+	body := c.Request.Body
+	return body != nil && body != http.NoBody && c.Request.ContentLength != 0
+}
+
+// decodeJSON decodes the body into v; an empty chunked body is not an error
+func (c *Context) decodeJSON(v interface{}) error {
+	err := json.NewDecoder(c.Request.Body).Decode(v)
+	if err != nil && err != io.EOF {
+		return NewHTTPError(http.StatusBadRequest, "Invalid JSON body").Wrap(err)
+	}
+	return nil
+}
+
+// decodeXML decodes the body into v; an empty chunked body is not an error
+func (c *Context) decodeXML(v interface{}) error {
+	err := xml.NewDecoder(c.Request.Body).Decode(v)
+	if err != nil && err != io.EOF {
+		return NewHTTPError(http.StatusBadRequest, "Invalid XML body").Wrap(err)
+	}
+	return nil
+}
+
+// parseForm parses a form body into c.form
+func (c *Context) parseForm(mediaType string) error {
+	var err error
+	if mediaType == "multipart/form-data" {
+		err = c.Request.ParseMultipartForm(defaultMultipartMemory)
+	} else {
+		err = c.Request.ParseForm()
+	}
+	if err != nil {
+		return NewHTTPError(http.StatusBadRequest, "Invalid form body").Wrap(err)
+	}
+	c.form = c.Request.PostForm
+	return nil
+}
+
+// bindFields sets the tagged fields of v and validates it. Untagged fields
+// are also read from form bodies by their JSON name.
+func (c *Context) bindFields(v interface{}, isForm bool) error {
+	val, ok := structValue(v)
+	if !ok {
+		return nil
+	}
+
+	header := c.Request.Header
+	sources := []bindSource{
+		{tag: "param", lookup: func(name string) []string {
+			if value, ok := c.params[name]; ok {
+				return []string{value}
+			}
+			return nil
+		}},
+		{tag: "query", lookup: func(name string) []string { return c.query[name] }},
+		{tag: "header", lookup: header.Values},
+		{tag: "form", fallback: isForm, lookup: func(name string) []string { return c.form[name] }, files: c.formFiles},
+	}
+	if err := bindStruct(val, sources); err != nil {
+		return err
+	}
+	return validateStruct(v)
+}
+
+// bindValues fills v from values under tag, falling back to JSON names,
+// and validates it
+func (c *Context) bindValues(v interface{}, tag string, values map[string][]string) error {
+	val, ok := structValue(v)
+	if !ok {
+		return fmt.Errorf("bind target must be a pointer to a struct")
+	}
+
+	source := bindSource{tag: tag, fallback: true, lookup: func(name string) []string { return values[name] }}
+	if err := bindStruct(val, []bindSource{source}); err != nil {
+		return err
+	}
+	return validateStruct(v)
+}
+
+// structValue returns the struct v points to
+func structValue(v interface{}) (reflect.Value, bool) {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return reflect.Value{}, false
+	}
+	val = val.Elem()
+	return val, val.Kind() == reflect.Struct
+}
+
+// bindSource is where tagged fields get their values from
+type bindSource struct {
+	tag      string
+	fallback bool // also bind untagged fields by their JSON name
+	lookup   func(name string) []string
+	files    func(name string) []*multipart.FileHeader // uploaded files, for form fields
+}
+
+// bindStruct sets the fields of val from the sources, descending into
+// embedded structs
+func bindStruct(val reflect.Value, sources []bindSource) error {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue // unexported
+		}
+
+		fieldVal := val.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && !hasBindTag(field) {
+			if err := bindStruct(fieldVal, sources); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		for _, source := range sources {
+			name := field.Tag.Get(source.tag)
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				if !source.fallback || hasBindTag(field) {
+					continue
+				}
+				name = jsonFieldName(field)
+			}
+
+			if source.files != nil && isFileField(field.Type) {
+				bindFiles(fieldVal, source.files(name))
+				continue
+			}
+
+			values := source.lookup(name)
+			if len(values) == 0 {
+				continue
+			}
+			if err := setFieldValue(fieldVal, values); err != nil {
+				return NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid %s %s", source.tag, name)).Wrap(err)
+			}
+		}
+	}
+	return nil
+}
+
+// formFiles returns the files uploaded in a parsed multipart form field
+func (c *Context) formFiles(name string) []*multipart.FileHeader {
+	if c.Request.MultipartForm == nil {
+		return nil
+	}
+	return c.Request.MultipartForm.File[name]
+}
+
+// isFileField reports whether a field of typ takes uploaded files: a
+// *multipart.FileHeader or a []*multipart.FileHeader
+func isFileField(typ reflect.Type) bool {
+	return typ == fileHeaderType || (typ.Kind() == reflect.Slice && typ.Elem() == fileHeaderType)
+}
+
+// bindFiles sets a file field to the uploaded files, if there are any
+func bindFiles(field reflect.Value, files []*multipart.FileHeader) {
+	if len(files) == 0 {
+		return
+	}
+	if field.Kind() == reflect.Slice {
+		field.Set(reflect.ValueOf(files))
+	} else {
+		field.Set(reflect.ValueOf(files[0]))
+	}
+}
+
+// bindTags are the struct tags naming where a field is bound from
+var bindTags = []string{"param", "query", "header", "form"}
+
+// hasBindTag reports whether the field has any of the bind tags
+func hasBindTag(field reflect.StructField) bool {
+	for _, tag := range bindTags {
+		if _, ok := field.Tag.Lookup(tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// textUnmarshalerType is used to find fields that parse themselves, like time.Time
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setFieldValue converts values to the type of field and sets it. Slices
+// take every value, other types the first one.
+func setFieldValue(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Ptr {
+		value := reflect.New(field.Type().Elem())
+		if err := setFieldValue(value.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(value)
+		return nil
+	}
+
+	if reflect.PointerTo(field.Type()).Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(values[0]))
+	}
+
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setFieldValue(slice.Index(i), []string{value}); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setScalar(field, values[0])
+}
+
+// durationType is parsed with time.ParseDuration rather than as an integer
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setScalar parses value into a field of a basic kind
+func setScalar(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Slice: // []byte
+		field.SetBytes([]byte(value))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.Type() == durationType {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return errors.New("unsupported field type " + field.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package smallapi
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type bindUser struct {
+	ID     int                   `param:"id"`
+	Name   string                `json:"name" xml:"name" validate:"required"`
+	Tags   []string              `json:"tags" xml:"tag"`
+	Token  string                `header:"X-Token"`
+	Dry    bool                  `query:"dry_run"`
+	Avatar *multipart.FileHeader `form:"avatar"`
+}
+
+// multipartBody encodes fields and a file named avatar.png as a
+// multipart form, returning the body and its Content-Type
+func multipartBody(t *testing.T, fields map[string][]string) (string, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for name, values := range fields {
+		for _, value := range values {
+			w.WriteField(name, value)
+		}
+	}
+	file, err := w.CreateFormFile("avatar", "avatar.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("png"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String(), w.FormDataContentType()
+}
+
+func TestShouldBind(t *testing.T) {
+	form, formType := multipartBody(t, map[string][]string{"name": {"Ann"}, "tags": {"a", "b"}})
+	bound := bindUser{ID: 7, Name: "Ann", Tags: []string{"a", "b"}, Token: "abc", Dry: true}
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		chunked     bool
+		status      int
+		file        string // uploaded avatar
+	}{
+		{"json", "/users/7?dry_run=true", "application/json", `{"name":"Ann","tags":["a","b"]}`, false, 200, ""},
+		{"json with charset", "/users/7?dry_run=true", "application/json; charset=utf-8", `{"name":"Ann","tags":["a","b"]}`, false, 200, ""},
+		{"json by default", "/users/7?dry_run=true", "", `{"name":"Ann","tags":["a","b"]}`, false, 200, ""},
+		{"chunked json", "/users/7?dry_run=true", "application/json", `{"name":"Ann","tags":["a","b"]}`, true, 200, ""},
+		{"urlencoded", "/users/7?dry_run=true", "application/x-www-form-urlencoded", "name=Ann&tags=a&tags=b", false, 200, ""},
+		{"multipart", "/users/7?dry_run=true", formType, form, false, 200, "avatar.png"},
+		{"xml", "/users/7?dry_run=true", "application/xml", "<user><name>Ann</name><tag>a</tag><tag>b</tag></user>", false, 200, ""},
+		{"unsupported type", "/users/7", "text/plain", "Ann", false, 415, ""},
+		{"invalid json", "/users/7", "application/json", `{"name":`, false, 400, ""},
+		{"invalid xml", "/users/7", "application/xml", "<user><name>", false, 400, ""},
+		{"invalid param", "/users/seven", "application/json", `{"name":"Ann"}`, false, 400, ""},
+		{"invalid query", "/users/7?dry_run=maybe", "application/json", `{"name":"Ann"}`, false, 400, ""},
+		{"invalid", "/users/7", "application/json", `{"tags":["a"]}`, false, 422, ""},
+		{"empty chunked body", "/users/7", "application/json", "", true, 422, ""},
+	}
+
+	for _, tt := range tests {
+		var got bindUser
+		app := New()
+		app.Put("/users/:id", func(c *Context) {
+			if err := c.ShouldBind(&got); err != nil {
+				c.AbortWithError(err)
+				return
+			}
+			c.String("ok")
+		})
+
+		r := httptest.NewRequest("PUT", tt.path, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.chunked {
+			r.ContentLength = -1
+			r.TransferEncoding = []string{"chunked"}
+		}
+		r.Header.Set("X-Token", "abc")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+			continue
+		}
+		if tt.status == 422 && !strings.Contains(w.Body.String(), `"field":"name"`) {
+			t.Errorf("%s: body = %s", tt.name, w.Body)
+		}
+		if tt.status != 200 {
+			continue
+		}
+
+		if file := got.Avatar; (file == nil && tt.file != "") || (file != nil && file.Filename != tt.file) {
+			t.Errorf("%s: avatar = %+v, want %q", tt.name, file, tt.file)
+		}
+		got.Avatar = nil
+		if !reflect.DeepEqual(got, bound) {
+			t.Errorf("%s: bound %+v, want %+v", tt.name, got, bound)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package smallapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Blueprint is a reusable module of routes, middleware, templates, static
+// files and error handlers, similar to Flask's blueprints. It does nothing
+// until it is registered on an App with RegisterBlueprint.
+type Blueprint struct {
+	Name   string
+	Prefix string // default URL prefix, used when RegisterBlueprint gets ""
+
+	routes        []blueprintRoute
+	middleware    []MiddlewareFunc
+	templateDir   string
+	static        map[string]string
+	errorHandlers map[int]HandlerFunc
+}
+
+// blueprintRoute is a route recorded on a blueprint before registration
+type blueprintRoute struct {
+	method  string
+	path    string
+	handler HandlerFunc
+	name    string
+}
+
+// NewBlueprint creates a blueprint. The name namespaces its route names
+// ("auth.login") and templates ("auth/login.html").
+func NewBlueprint(name, prefix string) *Blueprint {
+	return &Blueprint{
+		Name:          name,
+		Prefix:        prefix,
+		static:        make(map[string]string),
+		errorHandlers: make(map[int]HandlerFunc),
+	}
+}
+
+// Get adds a GET route to the blueprint
+func (bp *Blueprint) Get(path string, handler HandlerFunc, name ...string) *Blueprint {
+	return bp.handle("GET", path, handler, name)
+}
+
+// Post adds a POST route to the blueprint
+func (bp *Blueprint) Post(path string, handler HandlerFunc, name ...string) *Blueprint {
+	return bp.handle("POST", path, handler, name)
+}
+
+// Put adds a PUT route to the blueprint
+func (bp *Blueprint) Put(path string, handler HandlerFunc, name ...string) *Blueprint {
+	return bp.handle("PUT", path, handler, name)
+}
+
+// Delete adds a DELETE route to the blueprint
+func (bp *Blueprint) Delete(path string, handler HandlerFunc, name ...string) *Blueprint {
+	return bp.handle("DELETE", path, handler, name)
+}
+
+// Patch adds a PATCH route to the blueprint
+func (bp *Blueprint) Patch(path string, handler HandlerFunc, name ...string) *Blueprint {
+	return bp.handle("PATCH", path, handler, name)
+}
+
+// Options adds an OPTIONS route to the blueprint
+func (bp *Blueprint) Options(path string, handler HandlerFunc, name ...string) *Blueprint {
+	return bp.handle("OPTIONS", path, handler, name)
+}
+
+// Route adds a route for multiple HTTP methods to the blueprint
+func (bp *Blueprint) Route(methods []string, path string, handler HandlerFunc, name ...string) *Blueprint {
+	for _, method := range methods {
+		bp.handle(method, path, handler, name)
+	}
+	return bp
+}
+
+// Use adds middleware that runs only for the blueprint's routes
+func (bp *Blueprint) Use(middleware MiddlewareFunc) *Blueprint {
+	bp.middleware = append(bp.middleware, middleware)
+	return bp
+}
+
+// Templates sets the blueprint's template directory. Its templates are
+// rendered by their namespaced name, e.g. c.Render("auth/login.html", data).
+func (bp *Blueprint) Templates(dir string) *Blueprint {
+	bp.templateDir = dir
+	return bp
+}
+
+// Static serves static files from a directory below the blueprint prefix
+func (bp *Blueprint) Static(urlPath, dirPath string) *Blueprint {
+	bp.static[urlPath] = dirPath
+	return bp
+}
+
+// ErrorHandler sets the handler rendering the given status for requests
+// under the blueprint prefix (404, 405) or handled by its routes (500)
+func (bp *Blueprint) ErrorHandler(status int, handler HandlerFunc) *Blueprint {
+	bp.errorHandlers[status] = handler
+	return bp
+}
+
+// handle records a route to be registered with the blueprint
+func (bp *Blueprint) handle(method, path string, handler HandlerFunc, name []string) *Blueprint {
+	route := blueprintRoute{method: method, path: path, handler: handler}
+	if len(name) > 0 {
+		route.name = name[0]
+	}
+	bp.routes = append(bp.routes, route)
+	return bp
+}
+
+// RegisterBlueprint mounts a blueprint under prefix, or under its own
+// Prefix when prefix is empty. Route names are namespaced with the
+// blueprint name, so a route named "login" becomes "auth.login".
+func (a *App) RegisterBlueprint(bp *Blueprint, prefix string) error {
+	if prefix == "" {
+		prefix = bp.Prefix
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	if bp.templateDir != "" {
+		if err := a.templates.LoadNamespace(bp.templateDir, bp.Name); err != nil {
+			return fmt.Errorf("blueprint %s: %v", bp.Name, err)
+		}
+	}
+
+	for urlPath, dirPath := range bp.static {
+		a.Static(prefix+urlPath, dirPath)
+	}
+
+	group := a.Group(prefix)
+	group.blueprint = bp
+	group.middleware = append(group.middleware, bp.middleware...)
+
+	for _, route := range bp.routes {
+		var name []string
+		if route.name != "" {
+			name = []string{bp.Name + "." + route.name}
+		}
+		group.handle(route.method, route.path, route.handler, name)
+	}
+
+	a.blueprints = append(a.blueprints, group)
+	return nil
+}
+
+// blueprintErrorHandler returns the handler a blueprint registered for
+// status, looking at the matched route's groups first and otherwise at the
+// blueprint whose prefix is the longest match for path
+func (a *App) blueprintErrorHandler(route *Route, path string, status int) HandlerFunc {
+	if route != nil {
+		for g := route.group; g != nil; g = g.parent {
+			if g.blueprint != nil {
+				return g.blueprint.errorHandlers[status]
+			}
+		}
+		return nil
+	}
+
+	var best *RouteGroup
+	for _, g := range a.blueprints {
+		if (path == g.prefix || strings.HasPrefix(path, g.prefix+"/")) &&
+			(best == nil || len(g.prefix) > len(best.prefix)) {
+			best = g
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.blueprint.errorHandlers[status]
+}
@@ -0,0 +1,94 @@
+package smallapi
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// paramType is a built-in route parameter type usable as ":name<type>"
+type paramType struct {
+	match  func(string) bool
+	schema map[string]interface{} // OpenAPI schema for the parameter
+}
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// paramTypes holds the built-in parameter types
+var paramTypes = map[string]paramType{
+	"int": {
+		match: func(s string) bool {
+			_, err := strconv.ParseInt(s, 10, 64)
+			return err == nil
+		},
+		schema: map[string]interface{}{"type": "integer", "format": "int64"},
+	},
+	"uint": {
+		match: func(s string) bool {
+			_, err := strconv.ParseUint(s, 10, 64)
+			return err == nil
+		},
+		schema: map[string]interface{}{"type": "integer", "format": "int64", "minimum": 0},
+	},
+	"float": {
+		match: func(s string) bool {
+			_, err := strconv.ParseFloat(s, 64)
+			return err == nil
+		},
+		schema: map[string]interface{}{"type": "number", "format": "double"},
+	},
+	"bool": {
+		match: func(s string) bool {
+			_, err := strconv.ParseBool(s)
+			return err == nil
+		},
+		schema: map[string]interface{}{"type": "boolean"},
+	},
+	"uuid": {
+		match:  uuidRegex.MatchString,
+		schema: map[string]interface{}{"type": "string", "format": "uuid"},
+	},
+	"alpha": {
+		match:  regexp.MustCompile(`^[a-zA-Z]+$`).MatchString,
+		schema: map[string]interface{}{"type": "string", "pattern": "^[a-zA-Z]+$"},
+	},
+	"alphanum": {
+		match:  regexp.MustCompile(`^[a-zA-Z0-9]+$`).MatchString,
+		schema: map[string]interface{}{"type": "string", "pattern": "^[a-zA-Z0-9]+$"},
+	},
+}
+
+// constraintMatcher returns the matcher for a parameter constraint, which is
+// either the name of a built-in type or a regular expression that must match
+// the whole segment
+func constraintMatcher(constraint, path string) func(string) bool {
+	if t, ok := paramTypes[constraint]; ok {
+		return t.match
+	}
+
+	regex, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		panic(fmt.Sprintf("smallapi: invalid parameter constraint %q in %q: %v", constraint, path, err))
+	}
+	return regex.MatchString
+}
+
+// paramSchema returns the OpenAPI schema describing a path segment
+func paramSchema(segment Segment) map[string]interface{} {
+	if segment.Constraint == "" {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	if t, ok := paramTypes[segment.Constraint]; ok {
+		schema := make(map[string]interface{}, len(t.schema))
+		for k, v := range t.schema {
+			schema[k] = v
+		}
+		return schema
+	}
+
+	return map[string]interface{}{
+		"type":    "string",
+		"pattern": "^(?:" + segment.Constraint + ")$",
+	}
+}
@@ -1,6 +1,7 @@
 package smallapi
 
 import (
+        "context"
         "encoding/json"
         "fmt"
         "html/template"
@@ -9,6 +10,7 @@ import (
         "net/url"
         "strconv"
         "strings"
+        "time"
 )
 
 // Context provides request/response handling similar to Flask's request and g objects
@@ -16,24 +18,33 @@ type Context struct {
         Request    *http.Request
         Response   http.ResponseWriter
         app        *App
+        route      *Route
         params     map[string]string
         query      url.Values
         form       url.Values
         session    *Session
+        sessions   *SessionManager // loads session on first use
         data       map[string]interface{} // Similar to Flask's g object
-        written    bool
+        writer     *ResponseWriter        // tracks what has been sent through Response
         statusCode int
+        err        error            // error being handled, see Error
+        handlers   []MiddlewareFunc // middleware chain ending with the handler
+        index      int              // position of the running middleware in handlers
 }
 
 // NewContext creates a new context for a request
 func NewContext(w http.ResponseWriter, r *http.Request, app *App, sessionManager *SessionManager) *Context {
+        writer := newResponseWriter(w)
         ctx := &Context{
                 Request:    r,
-                Response:   w,
+                Response:   writer,
                 app:        app,
+                writer:     writer,
                 params:     make(map[string]string),
                 data:       make(map[string]interface{}),
+                sessions:   sessionManager,
                 statusCode: 200,
+                index:      -1,
         }
 
         // Parse query parameters
@@ -45,13 +56,21 @@ func NewContext(w http.ResponseWriter, r *http.Request, app *App, sessionManager
                 ctx.form = r.PostForm
         }
 
-        // Initialize session
-        ctx.session = sessionManager.GetSession(r, w)
-
         return ctx
 }
 
-// Param returns a URL parameter by name (e.g., /users/:id)
+// Next runs the rest of the middleware chain and the handler. Middleware
+// calls it to continue the request; code after it sees the response, also
+// when the handler was stopped with Abort.
+func (c *Context) Next() {
+        c.index++
+        if c.index < len(c.handlers) {
+                defer c.recoverAbort()
+                c.handlers[c.index](c)
+        }
+}
+
+// Param returns a URL parameter by name (e.g., /users/:id or /files/*path)
 func (c *Context) Param(name string) string {
         return c.params[name]
 }
@@ -65,6 +84,45 @@ func (c *Context) ParamInt(name string) (int, error) {
         return strconv.Atoi(value)
 }
 
+// ParamInt64 returns a URL parameter as a 64-bit integer (e.g., /users/:id<int>)
+func (c *Context) ParamInt64(name string) (int64, error) {
+        value := c.params[name]
+        if value == "" {
+                return 0, fmt.Errorf("parameter %s not found", name)
+        }
+        return strconv.ParseInt(value, 10, 64)
+}
+
+// ParamFloat returns a URL parameter as a float (e.g., /price/:amount<float>)
+func (c *Context) ParamFloat(name string) (float64, error) {
+        value := c.params[name]
+        if value == "" {
+                return 0, fmt.Errorf("parameter %s not found", name)
+        }
+        return strconv.ParseFloat(value, 64)
+}
+
+// ParamBool returns a URL parameter as a boolean (e.g., /flags/:on<bool>)
+func (c *Context) ParamBool(name string) (bool, error) {
+        value := c.params[name]
+        if value == "" {
+                return false, fmt.Errorf("parameter %s not found", name)
+        }
+        return strconv.ParseBool(value)
+}
+
+// ParamUUID returns a URL parameter that must be a UUID (e.g., /orders/:id<uuid>)
+func (c *Context) ParamUUID(name string) (string, error) {
+        value := c.params[name]
+        if value == "" {
+                return "", fmt.Errorf("parameter %s not found", name)
+        }
+        if !uuidRegex.MatchString(value) {
+                return "", fmt.Errorf("parameter %s is not a valid UUID", name)
+        }
+        return value, nil
+}
+
 // Query returns a query parameter by name
 func (c *Context) Query(name string) string {
         return c.query.Get(name)
@@ -90,29 +148,30 @@ func (c *Context) QueryInt(name string) (int, error) {
 
 // Form returns a form field value
 func (c *Context) Form(name string) string {
-        return c.form.Get(name)
+        return c.formValues().Get(name)
 }
 
 // FormDefault returns a form field with a default value
 func (c *Context) FormDefault(name, defaultValue string) string {
-        value := c.form.Get(name)
+        value := c.formValues().Get(name)
         if value == "" {
                 return defaultValue
         }
         return value
 }
 
-// JSON parses request body as JSON into the provided struct
-// If called without arguments, it sends JSON response
-func (c *Context) JSON(v interface{}) error {
-        if c.Request.Method == "GET" || c.Request.ContentLength == 0 {
-                // This is a response
-                return c.sendJSON(v)
+// formValues returns the form fields, parsing a multipart body on first use
+func (c *Context) formValues() url.Values {
+        if len(c.form) == 0 && c.Request.MultipartForm == nil && c.contentType() == "multipart/form-data" {
+                c.MultipartForm()
         }
+        return c.form
+}
 
-        // This is parsing request JSON
-        decoder := json.NewDecoder(c.Request.Body)
-        return decoder.Decode(v)
+// JSON sends v as a JSON response. Use Bind or ShouldBind to read a JSON
+// request body.
+func (c *Context) JSON(v interface{}) error {
+        return c.sendJSON(v)
 }
 
 // sendJSON sends a JSON response
@@ -121,7 +180,6 @@ func (c *Context) sendJSON(v interface{}) error {
         if c.statusCode != 200 {
                 c.Response.WriteHeader(c.statusCode)
         }
-        c.written = true
         return json.NewEncoder(c.Response).Encode(v)
 }
 
@@ -131,7 +189,6 @@ func (c *Context) String(text string) {
         if c.statusCode != 200 {
                 c.Response.WriteHeader(c.statusCode)
         }
-        c.written = true
         c.Response.Write([]byte(text))
 }
 
@@ -141,7 +198,6 @@ func (c *Context) HTML(html string) {
         if c.statusCode != 200 {
                 c.Response.WriteHeader(c.statusCode)
         }
-        c.written = true
         c.Response.Write([]byte(html))
 }
 
@@ -151,7 +207,6 @@ func (c *Context) Render(templateName string, data interface{}) error {
         if c.statusCode != 200 {
                 c.Response.WriteHeader(c.statusCode)
         }
-        c.written = true
 
         // Use the app's template engine
         if c.app.templates != nil {
@@ -190,13 +245,11 @@ func (c *Context) Render(templateName string, data interface{}) error {
 // File sends a file response
 func (c *Context) File(filePath string) {
         http.ServeFile(c.Response, c.Request, filePath)
-        c.written = true
 }
 
 // Redirect sends a redirect response
 func (c *Context) Redirect(url string) {
         http.Redirect(c.Response, c.Request, url, http.StatusFound)
-        c.written = true
 }
 
 // Status sets the HTTP status code for the response
@@ -205,6 +258,70 @@ func (c *Context) Status(code int) *Context {
         return c
 }
 
+// Error returns the error being handled, inside error handlers and
+// teardown functions
+func (c *Context) Error() error {
+        return c.err
+}
+
+// Writer returns the writer tracking the response status, size and timing
+func (c *Context) Writer() *ResponseWriter {
+        return c.writer
+}
+
+// Written reports whether the response headers have been sent
+func (c *Context) Written() bool {
+        return c.writer.Written()
+}
+
+// StatusCode returns the status sent, or the one set with Status if the
+// headers have not been written yet
+func (c *Context) StatusCode() int {
+        if c.writer.Written() {
+                return c.writer.Status()
+        }
+        return c.statusCode
+}
+
+// Context implements context.Context for the request, so it can be passed
+// to database and HTTP clients to stop their work when the client goes
+// away or a Timeout expires
+var _ context.Context = (*Context)(nil)
+
+// Deadline returns when the request times out, if it has a deadline
+func (c *Context) Deadline() (time.Time, bool) {
+        return c.Request.Context().Deadline()
+}
+
+// Done is closed when the client disconnects or the request times out
+//
+//	select {
+//	case result := <-work:
+//	        c.JSON(result)
+//	case <-c.Done():
+//	        return // nobody is waiting for the result anymore
+//	}
+func (c *Context) Done() <-chan struct{} {
+        return c.Request.Context().Done()
+}
+
+// Err returns why Done was closed: context.Canceled when the client went
+// away, context.DeadlineExceeded when the request timed out
+func (c *Context) Err() error {
+        return c.Request.Context().Err()
+}
+
+// Value returns the context data set with Set for string keys, and
+// otherwise the value of the request context
+func (c *Context) Value(key interface{}) interface{} {
+        if name, ok := key.(string); ok {
+                if value, ok := c.data[name]; ok {
+                        return value
+                }
+        }
+        return c.Request.Context().Value(key)
+}
+
 // Header sets a response header
 func (c *Context) Header(key, value string) *Context {
         c.Response.Header().Set(key, value)
@@ -222,8 +339,24 @@ func (c *Context) GetCookie(name string) (*http.Cookie, error) {
         return c.Request.Cookie(name)
 }
 
-// Session returns the session for this request
+// Route returns the matched route, or nil before routing and for unmatched requests
+func (c *Context) Route() *Route {
+        return c.route
+}
+
+// Session returns the session for this request, loading it on first use.
+// Requests that never call it do not touch the session store, and a new
+// session only gets a cookie once data is put in it.
 func (c *Context) Session() *Session {
+        if c.session == nil {
+                session, sessions := c.sessions.GetSession(c.Request, c.Response), c.sessions
+                c.session = session
+
+                // Saved before the response headers are sent
+                c.writer.onHeader(func() {
+                        sessions.saveSession(session)
+                })
+        }
         return c.session
 }
 
@@ -0,0 +1,251 @@
+package smallapi
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxCookieSize is the size browsers are guaranteed to store for a cookie,
+// counting its name, value and attributes
+const maxCookieSize = 4096
+
+// CookieSessionConfig configures sessions kept in the cookie itself
+type CookieSessionConfig struct {
+	// Keys sign the cookie with HMAC-SHA256. The first key signs new
+	// cookies; all of them are accepted, so a key can be rotated by
+	// putting a new one first. Keys must be at least 32 random bytes.
+	Keys [][]byte
+
+	// Encrypt also encrypts the cookie with AES-256-GCM, so clients cannot
+	// read the session data
+	Encrypt bool
+}
+
+// CookieSessions keeps the whole session in a signed, and optionally
+// encrypted, cookie instead of a SessionStore, like Flask's default
+// sessions. Nothing is stored on the server, so any replica can serve any
+// request, but a session cannot be revoked before it expires and must fit
+// in a 4 KB cookie. Call it when setting up the application.
+//
+//	app := smallapi.New().CookieSessions(smallapi.CookieSessionConfig{
+//	        Keys:    [][]byte{newKey, oldKey},
+//	        Encrypt: true,
+//	})
+func (a *App) CookieSessions(config CookieSessionConfig) *App {
+	codec, err := newCookieCodec(config)
+	if err != nil {
+		panic(err)
+	}
+	a.sessions.cookies = codec
+	return a
+}
+
+// cookiePayload is what a session cookie holds
+type cookiePayload struct {
+	ID       string
+	Created  int64 // unix milliseconds
+	Accessed int64 // unix milliseconds
+	Expiry   int64 // unix milliseconds
+	Data     map[string]interface{}
+}
+
+// loadCookieSession decodes the session from its cookie, or starts an
+// empty one. New sessions get a cookie only once they are modified.
+func (sm *SessionManager) loadCookieSession(r *http.Request, w http.ResponseWriter) *Session {
+	if cookie, err := r.Cookie(sm.config.CookieName); err == nil && cookie.Value != "" {
+		var payload cookiePayload
+		err := sm.cookies.decode(cookie.Name, cookie.Value, &payload)
+		if err == nil {
+			created, accessed := time.UnixMilli(payload.Created), time.UnixMilli(payload.Accessed)
+			if time.Now().UnixMilli() <= payload.Expiry && !sm.timedOut(created, accessed) {
+				if payload.Data == nil {
+					payload.Data = make(map[string]interface{})
+				}
+				return &Session{
+					id:       payload.ID,
+					data:     payload.Data,
+					created:  created,
+					accessed: accessed,
+					w:        w,
+					manager:  sm,
+				}
+			}
+			sm.expired.Add(1)
+
+			session := sm.newSession(sm.generateSessionID(), w)
+			session.expireCookie = true
+			return session
+		}
+	}
+
+	return sm.newSession(sm.generateSessionID(), w)
+}
+
+// saveCookieSession writes a modified session to the response cookie, or
+// removes the cookie when the session was emptied. With an IdleTimeout,
+// the cookie is rewritten on every request to record the access.
+func (sm *SessionManager) saveCookieSession(session *Session, now, expiry time.Time) error {
+	if !session.dirty && sm.config.IdleTimeout == 0 {
+		return nil
+	}
+
+	if len(session.data) == 0 {
+		if !session.isNew || session.expireCookie {
+			sm.setSessionCookie(session.w, "", -1)
+		}
+		session.dirty, session.expireCookie = false, false
+		return nil
+	}
+
+	value, err := sm.cookies.encode(sm.config.CookieName, cookiePayload{
+		ID:       session.id,
+		Created:  session.created.UnixMilli(),
+		Accessed: now.UnixMilli(),
+		Expiry:   expiry.UnixMilli(),
+		Data:     session.data,
+	})
+	if err != nil {
+		return err
+	}
+
+	cookie := sm.sessionCookie(value, int(sm.config.MaxAge.Seconds()))
+	if size := len(cookie); size > maxCookieSize {
+		session.dirty = false // saving again cannot help
+		return fmt.Errorf("session cookie is %d bytes, over the %d byte limit browsers store", size, maxCookieSize)
+	}
+
+	session.w.Header().Add("Set-Cookie", cookie)
+	if session.isNew {
+		sm.created.Add(1)
+	}
+	session.isNew, session.dirty, session.expireCookie = false, false, false
+	return nil
+}
+
+// cookieCodec signs and encrypts session cookies with a list of keys, the
+// first of which is used for new cookies
+type cookieCodec struct {
+	signing [][]byte
+	ciphers []cipher.AEAD // nil unless encrypting
+}
+
+// newCookieCodec derives separate signing and encryption keys from each of
+// the configured keys
+func newCookieCodec(config CookieSessionConfig) (*cookieCodec, error) {
+	if len(config.Keys) == 0 {
+		return nil, errors.New("smallapi: cookie sessions need at least one key")
+	}
+
+	codec := &cookieCodec{}
+	for _, key := range config.Keys {
+		if len(key) < 32 {
+			return nil, errors.New("smallapi: cookie session keys must be at least 32 bytes")
+		}
+		codec.signing = append(codec.signing, deriveKey(key, "smallapi session signing"))
+		if config.Encrypt {
+			block, err := aes.NewCipher(deriveKey(key, "smallapi session encryption"))
+			if err != nil {
+				return nil, err
+			}
+			aead, err := cipher.NewGCM(block)
+			if err != nil {
+				return nil, err
+			}
+			codec.ciphers = append(codec.ciphers, aead)
+		}
+	}
+	return codec, nil
+}
+
+// deriveKey derives a 32-byte key for one purpose from a secret
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// encode serializes v, encrypts it if configured and signs it. The cookie
+// name is bound into the signature, so a value cannot be moved to another
+// cookie.
+func (c *cookieCodec) encode(name string, v interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return "", fmt.Errorf("encoding session: %w", err)
+	}
+	payload := buf.Bytes()
+
+	if c.ciphers != nil {
+		nonce := make([]byte, c.ciphers[0].NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		payload = c.ciphers[0].Seal(nonce, nonce, payload, []byte(name))
+	}
+
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(sign(c.signing[0], name, body)), nil
+}
+
+// decode verifies a cookie value with any of the keys, decrypts it and
+// deserializes it into v
+func (c *cookieCodec) decode(name, value string, v interface{}) error {
+	body, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return errors.New("malformed session cookie")
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return errors.New("malformed session cookie")
+	}
+
+	key := -1
+	for i, signing := range c.signing {
+		if hmac.Equal(mac, sign(signing, name, body)) {
+			key = i
+			break
+		}
+	}
+	if key < 0 {
+		return errors.New("invalid session cookie signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return errors.New("malformed session cookie")
+	}
+	if c.ciphers != nil {
+		aead := c.ciphers[key]
+		if len(payload) < aead.NonceSize() {
+			return errors.New("malformed session cookie")
+		}
+		payload, err = aead.Open(nil, payload[:aead.NonceSize()], payload[aead.NonceSize():], []byte(name))
+		if err != nil {
+			return errors.New("session cookie cannot be decrypted")
+		}
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(v); err != nil {
+		log.Printf("Error decoding session cookie: %v", err)
+		return err
+	}
+	return nil
+}
+
+// sign computes the HMAC of a cookie value
+func sign(key []byte, name, body string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name + "=" + body))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,207 @@
+package smallapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var (
+	cookieKey    = bytes.Repeat([]byte("k"), 32)
+	cookieOldKey = bytes.Repeat([]byte("o"), 32)
+)
+
+// testCodec creates a codec or fails the test
+func testCodec(t *testing.T, config CookieSessionConfig) *cookieCodec {
+	t.Helper()
+	codec, err := newCookieCodec(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codec
+}
+
+func TestCookieCodecKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys [][]byte
+		ok   bool
+	}{
+		{"no keys", nil, false},
+		{"short key", [][]byte{make([]byte, 16)}, false},
+		{"short old key", [][]byte{cookieKey, make([]byte, 31)}, false},
+		{"32 bytes", [][]byte{cookieKey}, true},
+		{"rotated", [][]byte{cookieKey, cookieOldKey}, true},
+	}
+
+	for _, tt := range tests {
+		for _, encrypt := range []bool{false, true} {
+			_, err := newCookieCodec(CookieSessionConfig{Keys: tt.keys, Encrypt: encrypt})
+			if (err == nil) != tt.ok {
+				t.Errorf("%s, encrypt=%v: err = %v", tt.name, encrypt, err)
+			}
+		}
+	}
+}
+
+func TestCookieCodecTamper(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		codec := testCodec(t, CookieSessionConfig{Keys: [][]byte{cookieKey}, Encrypt: encrypt})
+		value, err := codec.encode("session", cookiePayload{ID: "abc", Data: map[string]interface{}{"role": "user"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, signature, _ := strings.Cut(value, ".")
+
+		var payload cookiePayload
+		if err := codec.decode("session", value, &payload); err != nil || payload.Data["role"] != "user" {
+			t.Fatalf("encrypt=%v: decode = %+v, %v", encrypt, payload, err)
+		}
+
+		tests := []struct {
+			name, cookie, value string
+		}{
+			{"other cookie name", "other", value},
+			{"flipped body", "session", flipFirst(body) + "." + signature},
+			{"flipped signature", "session", body + "." + flipFirst(signature)},
+			{"no signature", "session", body},
+			{"empty signature", "session", body + "."},
+			{"bad base64", "session", body + ".!!!"},
+			{"truncated body", "session", body[:len(body)/2] + "." + signature},
+			{"empty", "session", ""},
+		}
+		for _, tt := range tests {
+			if err := codec.decode(tt.cookie, tt.value, &cookiePayload{}); err == nil {
+				t.Errorf("encrypt=%v, %s: decoded", encrypt, tt.name)
+			}
+		}
+	}
+}
+
+// flipFirst changes the first character of a base64 string, which unlike
+// the last one never holds only padding bits
+func flipFirst(s string) string {
+	first := "A"
+	if strings.HasPrefix(s, "A") {
+		first = "B"
+	}
+	return first + s[1:]
+}
+
+func TestCookieCodecRotation(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		old := testCodec(t, CookieSessionConfig{Keys: [][]byte{cookieOldKey}, Encrypt: encrypt})
+		rotated := testCodec(t, CookieSessionConfig{Keys: [][]byte{cookieKey, cookieOldKey}, Encrypt: encrypt})
+		dropped := testCodec(t, CookieSessionConfig{Keys: [][]byte{cookieKey}, Encrypt: encrypt})
+
+		oldValue, _ := old.encode("session", cookiePayload{ID: "old"})
+		newValue, _ := rotated.encode("session", cookiePayload{ID: "new"})
+
+		tests := []struct {
+			name  string
+			codec *cookieCodec
+			value string
+			ok    bool
+		}{
+			{"old cookie after rotation", rotated, oldValue, true},
+			{"new cookie after rotation", rotated, newValue, true},
+			{"old cookie after dropping the old key", dropped, oldValue, false},
+			{"new cookie after dropping the old key", dropped, newValue, true},
+			{"new cookie with only the old key", old, newValue, false},
+		}
+		for _, tt := range tests {
+			err := tt.codec.decode("session", tt.value, &cookiePayload{})
+			if (err == nil) != tt.ok {
+				t.Errorf("encrypt=%v, %s: err = %v", encrypt, tt.name, err)
+			}
+		}
+	}
+}
+
+func TestCookieCodecEncrypt(t *testing.T) {
+	tests := []struct {
+		encrypt  bool
+		readable bool
+	}{
+		{false, true},
+		{true, false},
+	}
+
+	for _, tt := range tests {
+		codec := testCodec(t, CookieSessionConfig{Keys: [][]byte{cookieKey}, Encrypt: tt.encrypt})
+		value, err := codec.encode("session", cookiePayload{Data: map[string]interface{}{"secret": "hunter2"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _, _ := strings.Cut(value, ".")
+		raw, _ := base64.RawURLEncoding.DecodeString(body)
+		if readable := bytes.Contains(raw, []byte("hunter2")); readable != tt.readable {
+			t.Errorf("encrypt=%v: readable = %v", tt.encrypt, readable)
+		}
+	}
+
+	// Cookies are not interchangeable between signed and encrypted codecs
+	signed := testCodec(t, CookieSessionConfig{Keys: [][]byte{cookieKey}})
+	value, _ := signed.encode("session", cookiePayload{ID: "abc"})
+	encrypted := testCodec(t, CookieSessionConfig{Keys: [][]byte{cookieKey}, Encrypt: true})
+	if err := encrypted.decode("session", value, &cookiePayload{}); err == nil {
+		t.Error("signed cookie decoded by an encrypting codec")
+	}
+}
+
+func TestCookieSessions(t *testing.T) {
+	app := New().CookieSessions(CookieSessionConfig{Keys: [][]byte{cookieKey}, Encrypt: true})
+	app.Get("/login", func(c *Context) {
+		c.Session().Set("user", "alice")
+		c.String("ok")
+	})
+	app.Get("/me", func(c *Context) {
+		user, _ := c.Session().Get("user").(string)
+		c.String(user)
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/login", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	cookie := cookies[0]
+
+	tampered := *cookie
+	body, signature, _ := strings.Cut(cookie.Value, ".")
+	tampered.Value = flipFirst(body) + "." + signature
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		want   string
+	}{
+		{"no cookie", nil, ""},
+		{"session cookie", cookie, "alice"},
+		{"tampered cookie", &tampered, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/me", nil)
+		if tt.cookie != nil {
+			r.AddCookie(&http.Cookie{Name: tt.cookie.Name, Value: tt.cookie.Value})
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("%s: user = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCookieSessionsShortKeyPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("16-byte key accepted")
+		}
+	}()
+	New().CookieSessions(CookieSessionConfig{Keys: [][]byte{make([]byte, 16)}})
+}
@@ -14,10 +14,11 @@ const (
 
 // SwaggerDoc represents the OpenAPI documentation structure
 type SwaggerDoc struct {
-	OpenAPI    string                 `json:"openapi"`
-	Info       map[string]interface{} `json:"info"`
-	Paths      map[string]interface{} `json:"paths"`
-	Components map[string]interface{} `json:"components,omitempty"`
+	OpenAPI    string                   `json:"openapi"`
+	Info       map[string]interface{}   `json:"info"`
+	Servers    []map[string]interface{} `json:"servers,omitempty"`
+	Paths      map[string]interface{}   `json:"paths"`
+	Components map[string]interface{}   `json:"components,omitempty"`
 }
 
 // SwaggerConfig holds the documentation configuration
@@ -70,7 +71,8 @@ func (app *App) EnableDocs(config *SwaggerConfig) {
 				"contact":     config.Contact,
 				"x-credits":   config.Credits,
 			},
-			Paths: generatePaths(app.router.routes),
+			Servers: generateServers(app.router.routes),
+			Paths:   generatePaths(app.router.routes),
 		}
 
 		c.JSON(doc)
@@ -78,7 +80,7 @@ func (app *App) EnableDocs(config *SwaggerConfig) {
 }
 
 // generatePaths converts routes to OpenAPI paths
-func generatePaths(routes []Route) map[string]interface{} {
+func generatePaths(routes []*Route) map[string]interface{} {
 	paths := make(map[string]interface{})
 
 	for _, route := range routes {
@@ -89,36 +91,148 @@ func generatePaths(routes []Route) map[string]interface{} {
 
 		method := strings.ToLower(route.Method)
 
-		pathItem := map[string]interface{}{
-			"summary":    fmt.Sprintf("%s %s", route.Method, route.Path),
-			"parameters": extractPathParameters(route.Path),
-		}
+		// Optional segments are documented as one path per variant,
+		// since OpenAPI path parameters are always required
+		for _, segments := range route.Pattern.Variants() {
+			path := openAPIPath(segments)
+
+			if paths[path] == nil {
+				paths[path] = make(map[string]interface{})
+			}
+			operations := paths[path].(map[string]interface{})
+
+			// The same operation on several hosts is documented once
+			// with one server per host
+			if existing, ok := operations[method].(map[string]interface{}); ok {
+				mergeServers(existing, route.Host)
+				continue
+			}
+
+			pathItem := map[string]interface{}{
+				"summary":    fmt.Sprintf("%s %s", route.Method, route.Path),
+				"parameters": extractPathParameters(segments),
+			}
+			if route.Host != "" {
+				pathItem["servers"] = []interface{}{hostServer(route.Host)}
+			}
 
-		if paths[route.Path] == nil {
-			paths[route.Path] = make(map[string]interface{})
+			operations[method] = pathItem
 		}
-		paths[route.Path].(map[string]interface{})[method] = pathItem
 	}
 
 	return paths
 }
 
-// extractPathParameters gets parameters from route path
-func extractPathParameters(path string) []map[string]interface{} {
+// generateServers lists the servers the routes are served from: the
+// current host for routes without a host, plus one entry per host pattern
+func generateServers(routes []*Route) []map[string]interface{} {
+	var servers []map[string]interface{}
+	var hosts []string
+	anyHost := false
+
+	for _, route := range routes {
+		if route.Host == "" {
+			anyHost = true
+		} else if !containsString(hosts, route.Host) {
+			hosts = append(hosts, route.Host)
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil
+	}
+	if anyHost {
+		servers = append(servers, map[string]interface{}{"url": "/"})
+	}
+	for _, host := range hosts {
+		servers = append(servers, hostServer(host))
+	}
+
+	return servers
+}
+
+// hostServer describes a host pattern as an OpenAPI server object, with a
+// server variable for each host parameter
+func hostServer(host string) map[string]interface{} {
+	pattern := compileHostPattern(host)
+
+	labels := make([]string, len(pattern.Labels))
+	variables := map[string]interface{}{
+		"scheme": map[string]interface{}{
+			"default": "https",
+			"enum":    []string{"https", "http"},
+		},
+	}
+	for i, label := range pattern.Labels {
+		if label.IsParam {
+			labels[i] = "{" + label.Name + "}"
+			variables[label.Name] = map[string]interface{}{
+				"default":     label.Name,
+				"description": fmt.Sprintf("Host parameter: %s", label.Name),
+			}
+		} else {
+			labels[i] = label.Value
+		}
+	}
+
+	return map[string]interface{}{
+		"url":       "{scheme}://" + strings.Join(labels, "."),
+		"variables": variables,
+	}
+}
+
+// mergeServers adds host to the servers of an already documented operation.
+// An operation served on any host needs no servers at all.
+func mergeServers(operation map[string]interface{}, host string) {
+	servers, ok := operation["servers"].([]interface{})
+	if !ok {
+		return
+	}
+	if host == "" {
+		delete(operation, "servers")
+		return
+	}
+	operation["servers"] = append(servers, hostServer(host))
+}
+
+// openAPIPath converts pattern segments to an OpenAPI path template,
+// e.g. "/users/:id" becomes "/users/{id}"
+func openAPIPath(segments []Segment) string {
+	parts := make([]string, len(segments))
+	for i, segment := range segments {
+		if segment.IsParam || segment.IsWildcard {
+			parts[i] = "{" + segment.Name + "}"
+		} else {
+			parts[i] = segment.Value
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// extractPathParameters gets parameters from route segments
+func extractPathParameters(segments []Segment) []map[string]interface{} {
 	var params []map[string]interface{}
 
-	segments := strings.Split(path, "/")
 	for _, segment := range segments {
-		if strings.HasPrefix(segment, ":") {
-			paramName := segment[1:]
+		switch {
+		case segment.IsParam:
+			params = append(params, map[string]interface{}{
+				"name":        segment.Name,
+				"in":          "path",
+				"required":    true,
+				"description": fmt.Sprintf("Path parameter: %s", segment.Name),
+				"schema":      paramSchema(segment),
+			})
+		case segment.IsWildcard:
 			params = append(params, map[string]interface{}{
-				"name":        paramName,
+				"name":        segment.Name,
 				"in":          "path",
 				"required":    true,
-				"description": fmt.Sprintf("Path parameter: %s", paramName),
+				"description": fmt.Sprintf("Catch-all parameter: %s (remainder of the path, may contain slashes or be empty)", segment.Name),
 				"schema": map[string]string{
 					"type": "string",
 				},
+				"x-catch-all": true,
 			})
 		}
 	}
@@ -228,8 +342,16 @@ func NewDocumentation() *Documentation {
 
 // AddRoute adds a route to the documentation
 func (d *Documentation) AddRoute(method, path string, handler HandlerFunc) {
-	if d.doc.Paths[path] == nil {
-		d.doc.Paths[path] = &Path{}
+	for _, segments := range compilePattern(path).Variants() {
+		d.addOperation(method, path, segments)
+	}
+}
+
+// addOperation documents one variant of a route pattern
+func (d *Documentation) addOperation(method, path string, segments []Segment) {
+	key := openAPIPath(segments)
+	if d.doc.Paths[key] == nil {
+		d.doc.Paths[key] = &Path{}
 	}
 
 	operation := &Operation{
@@ -241,31 +363,36 @@ func (d *Documentation) AddRoute(method, path string, handler HandlerFunc) {
 	}
 
 	// Extract path parameters
-	if strings.Contains(path, ":") {
-		segments := strings.Split(strings.Trim(path, "/"), "/")
-		for _, segment := range segments {
-			if strings.HasPrefix(segment, ":") {
-				paramName := segment[1:]
-				operation.Parameters = append(operation.Parameters, Parameter{
-					Name:        paramName,
-					In:          "path",
-					Description: fmt.Sprintf("Path parameter: %s", paramName),
-					Required:    true,
-					Type:        "string",
-				})
-			}
+	for _, segment := range segments {
+		switch {
+		case segment.IsParam:
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name:        segment.Name,
+				In:          "path",
+				Description: fmt.Sprintf("Path parameter: %s", segment.Name),
+				Required:    true,
+				Type:        paramSchema(segment)["type"].(string),
+			})
+		case segment.IsWildcard:
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name:        segment.Name,
+				In:          "path",
+				Description: fmt.Sprintf("Catch-all parameter: %s (remainder of the path)", segment.Name),
+				Required:    true,
+				Type:        "string",
+			})
 		}
 	}
 
 	switch strings.ToUpper(method) {
 	case "GET":
-		d.doc.Paths[path].Get = operation
+		d.doc.Paths[key].Get = operation
 	case "POST":
-		d.doc.Paths[path].Post = operation
+		d.doc.Paths[key].Post = operation
 	case "PUT":
-		d.doc.Paths[path].Put = operation
+		d.doc.Paths[key].Put = operation
 	case "DELETE":
-		d.doc.Paths[path].Delete = operation
+		d.doc.Paths[key].Delete = operation
 	}
 }
 
@@ -0,0 +1,39 @@
+package smallapi
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGeneratePathsCatchAll(t *testing.T) {
+	router := testRouter(t, "/files/*rest", "/docs/*", "/posts/:year/:month?")
+	paths := generatePaths(router.routes)
+
+	for path, param := range map[string]string{
+		"/files/{rest}":         "rest",
+		"/docs/{path}":          "path",
+		"/posts/{year}/{month}": "month",
+		"/posts/{year}":         "year",
+	} {
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s missing from %v", path, paths)
+			continue
+		}
+		params := item["get"].(map[string]interface{})["parameters"].([]map[string]interface{})
+		if last := params[len(params)-1]["name"]; last != param {
+			t.Errorf("%s: last parameter %v, want %s", path, last, param)
+		}
+	}
+}
+
+func TestUnnamedCatchAllParam(t *testing.T) {
+	app := New()
+	app.Get("/docs/*", func(c *Context) { c.String(c.Param("path")) })
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/docs/guide/intro.md", nil))
+	if w.Body.String() != "guide/intro.md" {
+		t.Fatalf("c.Param(\"path\") = %q", w.Body.String())
+	}
+}
@@ -0,0 +1,223 @@
+package smallapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+)
+
+// HTTPError is an error with the HTTP status it should be answered with.
+// Its message, code and details are sent to the client; the wrapped Err is
+// only logged.
+type HTTPError struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code,omitempty"`    // machine-readable, e.g. "user_not_found"
+	Message string      `json:"error"`             // shown to the client
+	Details interface{} `json:"details,omitempty"` // e.g. per-field validation errors
+	Err     error       `json:"-"`                 // internal cause, never sent
+}
+
+// NewHTTPError creates an HTTP error; an empty message defaults to the
+// status text
+//
+//	return smallapi.NewHTTPError(404, "user not found").WithCode("user_not_found")
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{Status: status, Message: message}
+}
+
+// Error describes the error, including its internal cause
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%d %s: %v", e.Status, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%d %s", e.Status, e.Message)
+}
+
+// Unwrap returns the internal cause
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// WithCode returns a copy of the error with a machine-readable code
+func (e *HTTPError) WithCode(code string) *HTTPError {
+	copy := *e
+	copy.Code = code
+	return &copy
+}
+
+// WithDetails returns a copy of the error with details for the client
+func (e *HTTPError) WithDetails(details interface{}) *HTTPError {
+	copy := *e
+	copy.Details = details
+	return &copy
+}
+
+// Wrap returns a copy of the error with an internal cause
+func (e *HTTPError) Wrap(err error) *HTTPError {
+	copy := *e
+	copy.Err = err
+	return &copy
+}
+
+// HandlerFuncE is a handler that returns an error instead of writing error
+// responses itself. Use Handle to register it.
+type HandlerFuncE func(*Context) error
+
+// Handle converts an error-returning handler into a HandlerFunc. A returned
+// error is rendered by the application's error handling: an *HTTPError or
+// *Problem with its own status, ValidationErrors as a 422, an expired
+// context deadline as a 504 and any other error as a 500.
+//
+//	app.Get("/users/:id", smallapi.Handle(func(c *smallapi.Context) error {
+//	        user, ok := users[c.Param("id")]
+//	        if !ok {
+//	                return smallapi.NewHTTPError(404, "user not found")
+//	        }
+//	        return c.JSON(user)
+//	}))
+func Handle(handler HandlerFuncE) HandlerFunc {
+	return func(c *Context) {
+		if err := handler(c); err != nil {
+			c.app.handleError(c, err)
+		}
+	}
+}
+
+// abortSignal is the panic value used by Abort to unwind the handler
+type abortSignal struct {
+	err error
+}
+
+// Abort stops the handler or middleware calling it and answers with an
+// error, like Flask's abort(). Middleware that called c.Next() still sees
+// the response once Next returns.
+//
+//	if !canEdit(user, post) {
+//	        c.Abort(403, "you cannot edit this post")
+//	}
+func (c *Context) Abort(status int, message string) {
+	c.AbortWithError(NewHTTPError(status, message))
+}
+
+// AbortWithError stops the handler or middleware calling it and answers
+// with err, see Abort
+func (c *Context) AbortWithError(err error) {
+	panic(&abortSignal{err: err})
+}
+
+// recoverAbort renders the error of an Abort in the running handler and
+// lets any other panic through
+func (c *Context) recoverAbort() {
+	if rec := recover(); rec != nil {
+		abort, ok := rec.(*abortSignal)
+		if !ok {
+			panic(rec)
+		}
+		c.app.handleError(c, abort.err)
+	}
+}
+
+// handleError answers the request with err, see errorStatus. Errors after
+// the response was sent are only logged.
+func (a *App) handleError(c *Context, err error) {
+	status := errorStatus(err)
+
+	if status >= 500 {
+		log.Printf("Error in handler: %v", err)
+	}
+	if c.Written() {
+		if status < 500 {
+			log.Printf("Error after the response was sent: %v", err)
+		}
+		c.err = err
+		return
+	}
+
+	a.renderError(c, c.route, status, err)
+}
+
+// errorStatus returns the status err is answered with: the status of an
+// *HTTPError or *Problem, 422 for ValidationErrors, 504 for an expired
+// context deadline and 500 otherwise
+func errorStatus(err error) int {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Status
+	}
+	var problem *Problem
+	if errors.As(err, &problem) && problem.Status != 0 {
+		return problem.Status
+	}
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return http.StatusUnprocessableEntity
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// publicError returns what the client may see of err: the message, code
+// and details of an *HTTPError, the field errors of ValidationErrors, and
+// only the status text for any other error
+func publicError(status int, err error) *HTTPError {
+	public := &HTTPError{Status: status, Message: http.StatusText(status)}
+
+	var httpErr *HTTPError
+	var validationErrs ValidationErrors
+	switch {
+	case errors.As(err, &httpErr):
+		public.Code = httpErr.Code
+		public.Details = httpErr.Details
+		if httpErr.Message != "" {
+			public.Message = httpErr.Message
+		}
+	case errors.As(err, &validationErrs):
+		public.Message = "Validation failed"
+		public.Details = validationErrs
+	}
+	return public
+}
+
+// sendError writes the default error body: a problem document for a
+// *Problem or in ProblemDetails mode, otherwise JSON unless the client
+// prefers HTML or plain text
+func (c *Context) sendError(status int, err error) {
+	var problem *Problem
+	if c.app.problems || errors.As(err, &problem) {
+		c.sendProblem(problemFor(c, status, err))
+		return
+	}
+
+	body := publicError(status, err)
+	offers := []string{"application/json", "text/html", "text/plain"}
+	switch negotiateType(c.Request.Header.Get("Accept"), offers) {
+	case "text/html":
+		title := fmt.Sprintf("%d %s", status, http.StatusText(status))
+		c.HTML(fmt.Sprintf(errorPage, title, title, html.EscapeString(body.Message)))
+	case "text/plain":
+		c.String(body.Message + "\n")
+	default:
+		c.sendJSON(body)
+	}
+}
+
+// errorPage is the default HTML error body
+const errorPage = `<!DOCTYPE html>
+<html>
+<head>
+    <title>%s</title>
+</head>
+<body>
+    <h1>%s</h1>
+    <p>%s</p>
+</body>
+</html>
+`
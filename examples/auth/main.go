@@ -2,6 +2,7 @@
 package main
 
 import (
+        "log"
         "time"
         "github.com/grandpaej/smallapi"
 )
@@ -50,14 +51,7 @@ func main() {
         app.Post("/register", func(c *smallapi.Context) {
                 var req RegisterRequest
                 
-                if err := c.JSON(&req); err != nil {
-                        c.Status(400).JSON(map[string]string{
-                                "error": "Invalid JSON format",
-                        })
-                        return
-                }
-                
-                if err := c.Validate(&req); err != nil {
+                if err := c.ShouldBind(&req); err != nil {
                         c.Status(400).JSON(map[string]string{
                                 "error": err.Error(),
                         })
@@ -73,16 +67,13 @@ func main() {
                 }
                 
                 // Auto-login after registration
-                token, _, err := authManager.Login(req.Username, req.Password)
-                if err != nil {
+                if _, err := authManager.LoginSession(c, req.Username, req.Password); err != nil {
                         c.Status(500).JSON(map[string]string{
                                 "error": "Registration successful but login failed",
                         })
                         return
                 }
                 
-                c.Session().Set("auth_token", token)
-                
                 profile := UserProfile{
                         ID:       user.ID,
                         Username: user.Username,
@@ -101,21 +92,15 @@ func main() {
         app.Post("/login", func(c *smallapi.Context) {
                 var req LoginRequest
                 
-                if err := c.JSON(&req); err != nil {
-                        c.Status(400).JSON(map[string]string{
-                                "error": "Invalid JSON format",
-                        })
-                        return
-                }
-                
-                if err := c.Validate(&req); err != nil {
+                if err := c.ShouldBind(&req); err != nil {
                         c.Status(400).JSON(map[string]string{
                                 "error": err.Error(),
                         })
                         return
                 }
                 
-                token, user, err := authManager.Login(req.Username, req.Password)
+                // Moves the session to a new ID against session fixation
+                user, err := authManager.LoginSession(c, req.Username, req.Password)
                 if err != nil {
                         c.Status(401).JSON(map[string]string{
                                 "error": "Invalid username or password",
@@ -123,8 +108,6 @@ func main() {
                         return
                 }
                 
-                c.Session().Set("auth_token", token)
-                
                 profile := UserProfile{
                         ID:       user.ID,
                         Username: user.Username,
@@ -140,10 +123,8 @@ func main() {
         })
         
         app.Post("/logout", func(c *smallapi.Context) {
-                token := c.Session().Get("auth_token")
-                if token != nil {
-                        authManager.Logout(token.(string))
-                        c.Session().Delete("auth_token")
+                if err := authManager.LogoutSession(c); err != nil {
+                        log.Printf("Error destroying session: %v", err)
                 }
                 
                 c.JSON(map[string]string{
@@ -151,6 +132,29 @@ func main() {
                 })
         })
         
+        // Log out of every device
+        app.Post("/logout/all", func(c *smallapi.Context) {
+                userID := c.GetString("user_id")
+                if userID == "" {
+                        c.Status(401).JSON(map[string]string{
+                                "error": "Authentication required",
+                        })
+                        return
+                }
+                
+                authManager.LogoutAll(userID)
+                removed, err := app.Sessions().DestroyAllFor(userID)
+                if err != nil {
+                        log.Printf("Error destroying sessions: %v", err)
+                }
+                c.Session().Destroy()
+                
+                c.JSON(map[string]interface{}{
+                        "message":  "Logged out everywhere",
+                        "sessions": removed,
+                })
+        })
+        
         // Check authentication status
         app.Get("/auth/status", func(c *smallapi.Context) {
                 user := c.Get("user")
@@ -200,7 +204,7 @@ func main() {
                 user := c.Get("user").(*smallapi.User)
                 
                 var updates map[string]interface{}
-                if err := c.JSON(&updates); err != nil {
+                if err := c.DecodeBody(&updates); err != nil {
                         c.Status(400).JSON(map[string]string{
                                 "error": "Invalid JSON format",
                         })
@@ -228,14 +232,7 @@ func main() {
                         NewPassword string `json:"new_password" validate:"required,min=6"`
                 }
                 
-                if err := c.JSON(&req); err != nil {
-                        c.Status(400).JSON(map[string]string{
-                                "error": "Invalid JSON format",
-                        })
-                        return
-                }
-                
-                if err := c.Validate(&req); err != nil {
+                if err := c.ShouldBind(&req); err != nil {
                         c.Status(400).JSON(map[string]string{
                                 "error": err.Error(),
                         })
@@ -172,14 +172,7 @@ func main() {
 	api.Post("/tasks", func(c *smallapi.Context) {
 		var task Task
 		
-		if err := c.JSON(&task); err != nil {
-			c.Status(400).JSON(map[string]string{
-				"error": "Invalid JSON format",
-			})
-			return
-		}
-		
-		if err := c.Validate(&task); err != nil {
+		if err := c.ShouldBind(&task); err != nil {
 			c.Status(400).JSON(map[string]string{
 				"error": err.Error(),
 			})
@@ -202,7 +195,7 @@ func main() {
 		}
 		
 		var updates Task
-		if err := c.JSON(&updates); err != nil {
+		if err := c.DecodeBody(&updates); err != nil {
 			c.Status(400).JSON(map[string]string{
 				"error": "Invalid JSON format",
 			})
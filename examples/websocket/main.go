@@ -365,14 +365,7 @@ func main() {
                         Username string `json:"username"`
                 }
                 
-                if err := c.JSON(&req); err != nil {
-                        c.Status(400).JSON(map[string]string{
-                                "error": "Invalid JSON format",
-                        })
-                        return
-                }
-                
-                if err := c.Validate(&req); err != nil {
+                if err := c.ShouldBind(&req); err != nil {
                         c.Status(400).JSON(map[string]string{
                                 "error": err.Error(),
                         })
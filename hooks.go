@@ -0,0 +1,287 @@
+package smallapi
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+// AfterRequestFunc is called with the response before it is sent
+type AfterRequestFunc func(c *Context, res *BufferedResponse)
+
+// TeardownFunc is called once the request is over. err is the error that
+// was handled for the request, such as a *PanicError, or nil.
+type TeardownFunc func(c *Context, err error)
+
+// BufferedResponse is the response seen by AfterRequest hooks. Hooks may
+// change its status, headers and body before it is sent.
+type BufferedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	streamed bool
+}
+
+// Streamed reports whether the handler already sent the response, by
+// flushing it or hijacking the connection. Changes to a streamed response
+// have no effect.
+func (r *BufferedResponse) Streamed() bool {
+	return r.streamed
+}
+
+// PanicError is the error handled when a handler panics
+type PanicError struct {
+	Value interface{}
+}
+
+// Error describes the panic
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error, so ErrorHandler can
+// match handlers that panic with a typed error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// errorTypeHandler is an error handler registered for an error
+type errorTypeHandler struct {
+	target  error        // matched with errors.Is
+	typ     reflect.Type // matched by type, when target is a typed nil pointer
+	handler HandlerFunc
+}
+
+// BeforeRequest registers a hook that runs before the handler, after the
+// route is matched. A hook that writes a response stops the request, like
+// returning a response from Flask's before_request.
+//
+//	app.BeforeRequest(func(c *smallapi.Context) {
+//	        if maintenance {
+//	                c.Status(503).String("Down for maintenance")
+//	        }
+//	})
+func (a *App) BeforeRequest(hook HandlerFunc) *App {
+	a.beforeRequest = append(a.beforeRequest, hook)
+	return a
+}
+
+// AfterRequest registers a hook that can change the response before it is
+// sent. While hooks are registered, responses are buffered until the hooks
+// have run. Hooks run in reverse order of registration, as in Flask.
+//
+//	app.AfterRequest(func(c *smallapi.Context, res *smallapi.BufferedResponse) {
+//	        res.Header.Set("X-Served-By", "smallapi")
+//	})
+func (a *App) AfterRequest(hook AfterRequestFunc) *App {
+	a.afterRequest = append(a.afterRequest, hook)
+	return a
+}
+
+// Teardown registers a function that runs after every request, even when
+// the handler panicked. It receives the error handled for the request.
+func (a *App) Teardown(fn TeardownFunc) *App {
+	a.teardown = append(a.teardown, fn)
+	return a
+}
+
+// ErrorHandler sets the handler rendering an error. key is either a status
+// code, a sentinel error matched with errors.Is, or a typed nil pointer
+// such as (*NotFoundError)(nil) matching every error of that type. The
+// status is already set when the handler runs and c.Error returns the
+// error being handled.
+//
+//	app.ErrorHandler(404, func(c *smallapi.Context) {
+//	        c.Render("404.html", nil)
+//	})
+//	app.ErrorHandler(sql.ErrNoRows, func(c *smallapi.Context) {
+//	        c.Status(404).JSON(map[string]string{"error": "no such record"})
+//	})
+func (a *App) ErrorHandler(key interface{}, handler HandlerFunc) *App {
+	switch key := key.(type) {
+	case int:
+		a.errorHandlers[key] = handler
+	case error:
+		h := errorTypeHandler{target: key, handler: handler}
+		if v := reflect.ValueOf(key); v.Kind() == reflect.Ptr && v.IsNil() {
+			h.typ = v.Type()
+		}
+		a.errorTypeHandlers = append(a.errorTypeHandlers, h)
+	default:
+		panic(fmt.Sprintf("smallapi: ErrorHandler key must be a status code or an error, got %T", key))
+	}
+	return a
+}
+
+// errorHandler returns the application handler for err or status
+func (a *App) errorHandler(status int, err error) HandlerFunc {
+	if err != nil {
+		for _, h := range a.errorTypeHandlers {
+			if h.matches(err) {
+				return h.handler
+			}
+		}
+	}
+	return a.errorHandlers[status]
+}
+
+// matches reports whether err or an error it wraps is handled by h
+func (h errorTypeHandler) matches(err error) bool {
+	if h.typ == nil {
+		return errors.Is(err, h.target)
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		if reflect.TypeOf(err) == h.typ {
+			return true
+		}
+	}
+	return false
+}
+
+// runRequestHooks runs the before-request hooks and the rest of the chain,
+// buffering the response for the after-request hooks
+func (a *App) runRequestHooks(ctx *Context) {
+	if len(a.beforeRequest) == 0 && len(a.afterRequest) == 0 {
+		ctx.Next()
+		return
+	}
+
+	if len(a.afterRequest) > 0 {
+		response, writer := ctx.Response, ctx.writer
+		buffer := &responseBuffer{dst: response}
+		ctx.writer = newResponseWriter(buffer)
+		ctx.Response = ctx.writer
+		defer func() {
+			ctx.Response, ctx.writer = response, writer
+		}()
+
+		a.runBeforeRequest(ctx)
+		ctx.Response, ctx.writer = response, writer
+
+		res := &BufferedResponse{
+			Status:   buffer.status,
+			Header:   response.Header(),
+			Body:     buffer.body.Bytes(),
+			streamed: buffer.streamed,
+		}
+		if res.Status == 0 {
+			res.Status = ctx.statusCode
+		}
+		for i := len(a.afterRequest) - 1; i >= 0; i-- {
+			a.afterRequest[i](ctx, res)
+		}
+		if !res.streamed {
+			res.send(response)
+		}
+		return
+	}
+
+	a.runBeforeRequest(ctx)
+}
+
+// runBeforeRequest runs the before-request hooks, then the rest of the
+// chain unless a hook wrote a response
+func (a *App) runBeforeRequest(ctx *Context) {
+	for _, hook := range a.beforeRequest {
+		hook(ctx)
+		if ctx.Written() {
+			return
+		}
+	}
+	ctx.Next()
+}
+
+// runTeardown calls the teardown functions in reverse order, logging any
+// panic so that the remaining ones still run
+func (a *App) runTeardown(ctx *Context) {
+	for i := len(a.teardown) - 1; i >= 0; i-- {
+		func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					log.Printf("Panic in teardown: %v", rec)
+				}
+			}()
+			a.teardown[i](ctx, ctx.err)
+		}()
+	}
+}
+
+// send writes the response to w
+func (r *BufferedResponse) send(w http.ResponseWriter) {
+	if r.Header.Get("Content-Length") != "" {
+		r.Header.Set("Content-Length", strconv.Itoa(len(r.Body)))
+	}
+	w.WriteHeader(r.Status)
+	if len(r.Body) > 0 {
+		w.Write(r.Body)
+	}
+}
+
+// responseBuffer holds the response for the after-request hooks. Flushing
+// or hijacking sends what was buffered and switches to writing through.
+type responseBuffer struct {
+	dst      http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	streamed bool
+}
+
+// Header returns the header map of the real response
+func (b *responseBuffer) Header() http.Header {
+	return b.dst.Header()
+}
+
+// WriteHeader records the status code
+func (b *responseBuffer) WriteHeader(code int) {
+	if b.streamed {
+		b.dst.WriteHeader(code)
+		return
+	}
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+// Write buffers the body
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	if b.streamed {
+		return b.dst.Write(p)
+	}
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// Flush sends the buffered response and streams the rest
+func (b *responseBuffer) Flush() {
+	if !b.streamed {
+		b.streamed = true
+		if b.status != 0 {
+			b.dst.WriteHeader(b.status)
+		}
+		b.dst.Write(b.body.Bytes())
+		b.body.Reset()
+	}
+	if flusher, ok := b.dst.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over, dropping the buffered response
+func (b *responseBuffer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := b.dst.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	b.streamed = true
+	return hijacker.Hijack()
+}
@@ -0,0 +1,119 @@
+package smallapi
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// HostPattern represents a compiled host pattern such as "api.example.com"
+// or ":tenant.example.com". Each ":name" label matches one DNS label.
+type HostPattern struct {
+	Host   string
+	Labels []Segment
+
+	names []string
+}
+
+// hostTrees holds the routes bound to one host pattern
+type hostTrees struct {
+	pattern *HostPattern
+	trees   map[string]*node
+}
+
+// compileHostPattern compiles a host pattern; host names are case-insensitive.
+// Request hosts are matched without their port, so a pattern with a port
+// could never match and is rejected.
+func compileHostPattern(host string) *HostPattern {
+	pattern := &HostPattern{Host: host}
+
+	for _, label := range strings.Split(strings.ToLower(host), ".") {
+		if strings.IndexByte(strings.TrimPrefix(label, ":"), ':') >= 0 {
+			panic(fmt.Sprintf("smallapi: host pattern %q has a port; host patterns match any port", host))
+		}
+		if strings.HasPrefix(label, ":") {
+			segment := compileParam(label, host)
+			pattern.Labels = append(pattern.Labels, segment)
+			pattern.names = append(pattern.names, segment.Name)
+		} else {
+			pattern.Labels = append(pattern.Labels, Segment{Value: label})
+		}
+	}
+
+	return pattern
+}
+
+// exact reports whether the pattern has no parameters
+func (p *HostPattern) exact() bool {
+	return len(p.names) == 0
+}
+
+// match checks a normalized host against the pattern and returns the
+// values of its parameters in order
+func (p *HostPattern) match(host string) ([]string, bool) {
+	if p.exact() {
+		return nil, host == strings.ToLower(p.Host)
+	}
+
+	var values []string
+	rest := host
+	for i, label := range p.Labels {
+		value := rest
+		if i < len(p.Labels)-1 {
+			end := strings.IndexByte(rest, '.')
+			if end < 0 {
+				return nil, false
+			}
+			value, rest = rest[:end], rest[end+1:]
+		} else {
+			rest = ""
+		}
+
+		if !label.IsParam {
+			if value != label.Value {
+				return nil, false
+			}
+			continue
+		}
+		if value == "" || strings.IndexByte(value, '.') >= 0 {
+			return nil, false
+		}
+		if label.match != nil && !label.match(value) {
+			return nil, false
+		}
+		values = append(values, value)
+	}
+
+	return values, true
+}
+
+// normalizeHost strips the port and lowercases a request host
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// Host creates a route group whose routes only match requests for the given
+// host. Host parameters are available through Context.Param.
+//
+//	api := app.Host("api.example.com")
+//	tenant := app.Host(":tenant.example.com")
+//	tenant.Get("/", func(c *smallapi.Context) { c.String(c.Param("tenant")) })
+func (a *App) Host(host string) *RouteGroup {
+	return &RouteGroup{
+		app:  a,
+		host: host,
+	}
+}
+
+// Host creates a nested group bound to the given host
+func (g *RouteGroup) Host(host string) *RouteGroup {
+	return &RouteGroup{
+		app:    g.app,
+		parent: g,
+		prefix: g.prefix,
+		host:   host,
+	}
+}
@@ -0,0 +1,57 @@
+package smallapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHostRouting(t *testing.T) {
+	router := NewRouter()
+	router.AddHost("api.example.com", "GET", "/status", func(c *Context) {})
+	router.AddHost(":tenant.example.com", "GET", "/status", func(c *Context) {})
+	router.AddHost(":id<int>.shard.example.com", "GET", "/status", func(c *Context) {})
+	router.Add("GET", "/status", func(c *Context) {})
+
+	cases := []struct {
+		host   string
+		want   string
+		params map[string]string
+	}{
+		{"api.example.com", "api.example.com", nil},
+		{"API.Example.com:8080", "api.example.com", nil},
+		{"api.example.com.", "api.example.com", nil},
+		{"acme.example.com", ":tenant.example.com", map[string]string{"tenant": "acme"}},
+		{"7.shard.example.com", ":id<int>.shard.example.com", map[string]string{"id": "7"}},
+		{"x.shard.example.com", "", nil},
+		{"a.b.example.com", "", nil},
+		{"other.org", "", nil},
+	}
+	for _, tc := range cases {
+		route, params := router.Lookup(tc.host, "GET", "/status")
+		if route == nil {
+			t.Errorf("%s: no match", tc.host)
+			continue
+		}
+		if route.Host != tc.want {
+			t.Errorf("%s: matched host %q, want %q", tc.host, route.Host, tc.want)
+		}
+		if len(params) > 0 || len(tc.params) > 0 {
+			if !reflect.DeepEqual(params, tc.params) {
+				t.Errorf("%s: params %v, want %v", tc.host, params, tc.params)
+			}
+		}
+	}
+}
+
+func TestHostPatternWithPort(t *testing.T) {
+	for _, host := range []string{"api.example.com:8080", ":tenant.example.com:443"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: registered without a panic", host)
+				}
+			}()
+			NewRouter().AddHost(host, "GET", "/", func(c *Context) {})
+		}()
+	}
+}
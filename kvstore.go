@@ -0,0 +1,377 @@
+package smallapi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// KVStore keeps sessions in a single file, an append-only log of records
+// indexed in memory, like a minimal embedded key-value database. Only the
+// index is held in memory; data is read from the file. The file is
+// compacted by GC once most of it is obsolete. It must be used by a
+// single process.
+type KVStore struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	size    int64 // end of the last valid record
+	live    int64 // bytes of the records still in use
+	entries map[string]kvEntry
+}
+
+// kvEntry locates the latest data of a session in the file
+type kvEntry struct {
+	offset   int64 // of the data
+	length   int64
+	expiry   int64 // unix nanoseconds
+	accessed int64 // unix nanoseconds
+	record   int64 // size of the whole put record
+	userID   string
+}
+
+// Record operations
+const (
+	kvPut byte = iota + 1
+	kvDelete
+	kvTouch
+)
+
+// kvHeaderSize is the op, expiry, access time, ID length, user ID length
+// and data length before the ID, user ID and data of a record; a CRC-32 of
+// the record follows them
+const kvHeaderSize = 1 + 8 + 8 + 2 + 2 + 4
+
+// kvMinCompact is the file size below which GC does not compact
+const kvMinCompact = 1 << 20
+
+// NewKVStore opens or creates the store file at path. A record left
+// incomplete by a crash is dropped.
+func NewKVStore(path string) (*KVStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &KVStore{path: path, file: file, entries: make(map[string]kvEntry)}
+	if err := s.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay rebuilds the index from the file, truncating it after the last
+// valid record
+func (s *KVStore) replay() error {
+	reader := bufio.NewReader(io.NewSectionReader(s.file, 0, 1<<62))
+	var offset int64
+	for {
+		op, times, id, userID, data, n, err := readKVRecord(reader)
+		if err != nil {
+			break // end of file or a torn record
+		}
+		entry := kvEntry{
+			offset: offset + int64(kvHeaderSize+len(id)+len(userID)),
+			length: int64(len(data)),
+			record: n,
+			userID: userID,
+		}
+		s.apply(op, id, times, entry)
+		offset += n
+	}
+
+	s.size = offset
+	return s.file.Truncate(offset)
+}
+
+// kvTimes are the expiry and access time of a record
+type kvTimes struct {
+	expiry, accessed int64 // unix nanoseconds
+}
+
+// apply updates the index with a record, located by entry for puts
+func (s *KVStore) apply(op byte, id string, times kvTimes, entry kvEntry) {
+	old, exists := s.entries[id]
+	switch op {
+	case kvPut:
+		if exists {
+			s.live -= old.record
+		}
+		entry.expiry, entry.accessed = times.expiry, times.accessed
+		s.entries[id] = entry
+		s.live += entry.record
+	case kvDelete:
+		if exists {
+			s.live -= old.record
+			delete(s.entries, id)
+		}
+	case kvTouch:
+		if exists {
+			old.expiry, old.accessed = times.expiry, times.accessed
+			s.entries[id] = old
+		}
+	}
+}
+
+// readKVRecord reads one record and returns it with its size
+func readKVRecord(r io.Reader) (op byte, times kvTimes, id, userID string, data []byte, size int64, err error) {
+	header := make([]byte, kvHeaderSize)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	op = header[0]
+	times.expiry = int64(binary.BigEndian.Uint64(header[1:9]))
+	times.accessed = int64(binary.BigEndian.Uint64(header[9:17]))
+	idLength := int(binary.BigEndian.Uint16(header[17:19]))
+	userLength := int(binary.BigEndian.Uint16(header[19:21]))
+	dataLength := int(binary.BigEndian.Uint32(header[21:25]))
+	if op < kvPut || op > kvTouch {
+		err = errors.New("kvstore: invalid record")
+		return
+	}
+
+	end := idLength + userLength + dataLength
+	body := make([]byte, end+4)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return
+	}
+	sum := crc32.NewIEEE()
+	sum.Write(header)
+	sum.Write(body[:end])
+	if sum.Sum32() != binary.BigEndian.Uint32(body[end:]) {
+		err = errors.New("kvstore: checksum mismatch")
+		return
+	}
+
+	id = string(body[:idLength])
+	userID = string(body[idLength : idLength+userLength])
+	data = body[idLength+userLength : end]
+	size = int64(kvHeaderSize + len(body))
+	return
+}
+
+// encodeKVRecord builds a record
+func encodeKVRecord(op byte, id, userID string, times kvTimes, data []byte) []byte {
+	record := make([]byte, kvHeaderSize, kvHeaderSize+len(id)+len(userID)+len(data)+4)
+	record[0] = op
+	binary.BigEndian.PutUint64(record[1:9], uint64(times.expiry))
+	binary.BigEndian.PutUint64(record[9:17], uint64(times.accessed))
+	binary.BigEndian.PutUint16(record[17:19], uint16(len(id)))
+	binary.BigEndian.PutUint16(record[19:21], uint16(len(userID)))
+	binary.BigEndian.PutUint32(record[21:25], uint32(len(data)))
+	record = append(record, id...)
+	record = append(record, userID...)
+	record = append(record, data...)
+	return binary.BigEndian.AppendUint32(record, crc32.ChecksumIEEE(record))
+}
+
+// append writes a record at the end of the file and indexes it
+func (s *KVStore) append(op byte, id, userID string, times kvTimes, data []byte, sync bool) error {
+	if len(id) > 0xffff {
+		return errors.New("kvstore: session ID too long")
+	}
+	if len(userID) > 0xffff {
+		return errors.New("kvstore: user ID too long")
+	}
+	if s.file == nil {
+		return os.ErrClosed
+	}
+
+	record := encodeKVRecord(op, id, userID, times, data)
+	if _, err := s.file.WriteAt(record, s.size); err != nil {
+		return err
+	}
+	if sync {
+		if err := s.file.Sync(); err != nil {
+			return err
+		}
+	}
+
+	s.apply(op, id, times, kvEntry{
+		offset: s.size + int64(kvHeaderSize+len(id)+len(userID)),
+		length: int64(len(data)),
+		record: int64(len(record)),
+		userID: userID,
+	})
+	s.size += int64(len(record))
+	return nil
+}
+
+// Load reads a session from the file
+func (s *KVStore) Load(id string) (*SessionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok || time.Now().UnixNano() > entry.expiry {
+		return nil, ErrSessionNotFound
+	}
+	if s.file == nil {
+		return nil, os.ErrClosed
+	}
+	encoded := make([]byte, entry.length)
+	if _, err := s.file.ReadAt(encoded, entry.offset); err != nil {
+		return nil, err
+	}
+	record, err := decodeSessionRecord(encoded)
+	if err != nil {
+		return nil, err
+	}
+	record.Expiry, record.Accessed = time.Unix(0, entry.expiry), time.Unix(0, entry.accessed)
+	return record, nil
+}
+
+// Save appends a session to the file
+func (s *KVStore) Save(id string, record *SessionRecord) error {
+	encoded, err := encodeSessionRecord(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	times := kvTimes{expiry: record.Expiry.UnixNano(), accessed: record.Accessed.UnixNano()}
+	return s.append(kvPut, id, record.UserID, times, encoded, true)
+}
+
+// Delete records the removal of a session
+func (s *KVStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[id]; !ok {
+		return nil
+	}
+	return s.append(kvDelete, id, "", kvTimes{}, nil, true)
+}
+
+// DeleteUser records the removal of the sessions of a user, found by the
+// user ID kept in the index
+func (s *KVStore) DeleteUser(userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, entry := range s.entries {
+		if entry.userID != userID {
+			continue
+		}
+		if err := s.append(kvDelete, id, "", kvTimes{}, nil, true); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Touch records an access to a session. It is not synced to disk: after a
+// crash the session keeps its previous expiry.
+func (s *KVStore) Touch(id string, accessed, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[id]; !ok {
+		return ErrSessionNotFound
+	}
+	times := kvTimes{expiry: expiry.UnixNano(), accessed: accessed.UnixNano()}
+	return s.append(kvTouch, id, "", times, nil, false)
+}
+
+// GC drops expired sessions from the index and compacts the file when
+// less than half of it is still in use
+func (s *KVStore) GC() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now, removed := time.Now().UnixNano(), 0
+	for id, entry := range s.entries {
+		if now > entry.expiry {
+			s.live -= entry.record
+			delete(s.entries, id)
+			removed++
+		}
+	}
+
+	if s.size < kvMinCompact || s.live*2 > s.size {
+		return removed, nil
+	}
+	return removed, s.compact()
+}
+
+// Count returns the number of sessions in the index
+func (s *KVStore) Count() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries), nil
+}
+
+// compact rewrites the live sessions to a new file and replaces the old
+// one with it
+func (s *KVStore) compact() error {
+	if s.file == nil {
+		return os.ErrClosed
+	}
+
+	tmpPath := s.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	entries := make(map[string]kvEntry, len(s.entries))
+	var size int64
+	for id, entry := range s.entries {
+		data := make([]byte, entry.length)
+		if _, err := s.file.ReadAt(data, entry.offset); err != nil {
+			return fail(err)
+		}
+		times := kvTimes{expiry: entry.expiry, accessed: entry.accessed}
+		record := encodeKVRecord(kvPut, id, entry.userID, times, data)
+		if _, err := tmp.WriteAt(record, size); err != nil {
+			return fail(err)
+		}
+		entries[id] = kvEntry{
+			offset:   size + int64(kvHeaderSize+len(id)+len(entry.userID)),
+			length:   entry.length,
+			expiry:   entry.expiry,
+			accessed: entry.accessed,
+			record:   int64(len(record)),
+			userID:   entry.userID,
+		}
+		size += int64(len(record))
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fail(err)
+	}
+
+	s.file.Close()
+	s.file, s.entries, s.size, s.live = tmp, entries, size, size
+	return nil
+}
+
+// Close closes the store file
+func (s *KVStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
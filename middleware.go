@@ -1,31 +1,36 @@
 package smallapi
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
-// Logger returns a middleware that logs requests
+// Logger returns a middleware that logs requests once they have been handled
 func Logger() MiddlewareFunc {
-	return func(c *Context) bool {
+	return func(c *Context) {
 		start := time.Now()
-		
+
 		// Process request
-		defer func() {
-			duration := time.Since(start)
-			log.Printf("%s %s %d %v", 
-				c.Method(), 
-				c.Path(), 
-				c.statusCode, 
-				duration,
-			)
-		}()
-		
-		return true
+		c.Next()
+
+		duration := time.Since(start)
+		log.Printf("%s %s %d %dB %v",
+			c.Method(),
+			c.Path(),
+			c.StatusCode(),
+			c.writer.Size(),
+			duration,
+		)
 	}
 }
 
@@ -47,7 +52,7 @@ type CORSConfig struct {
 
 // CORSWithConfig returns a CORS middleware with custom configuration
 func CORSWithConfig(config CORSConfig) MiddlewareFunc {
-	return func(c *Context) bool {
+	return func(c *Context) {
 		origin := c.Request.Header.Get("Origin")
 		
 		// Check if origin is allowed
@@ -70,28 +75,31 @@ func CORSWithConfig(config CORSConfig) MiddlewareFunc {
 		// Handle preflight request
 		if c.Method() == "OPTIONS" {
 			c.Status(204)
-			return false
+			c.Response.WriteHeader(204)
+			return
 		}
 		
-		return true
+		c.Next()
 	}
 }
 
-// Recovery returns a middleware that recovers from panics
+// Recovery returns a middleware that recovers from panics in the rest of
+// the chain and renders a 500 error if nothing was written yet
 func Recovery() MiddlewareFunc {
-	return func(c *Context) bool {
+	return func(c *Context) {
 		defer func() {
 			if r := recover(); r != nil {
 				log.Printf("Panic recovered: %v", r)
-				if !c.written {
-					c.Status(500).JSON(map[string]string{
-						"error": "Internal Server Error",
-					})
+				err := &PanicError{Value: r}
+				if !c.Written() {
+					c.app.renderError(c, c.route, 500, err)
+				} else {
+					c.err = err
 				}
 			}
 		}()
 		
-		return true
+		c.Next()
 	}
 }
 
@@ -105,12 +113,11 @@ func RateLimit(requestsPerMinute int) MiddlewareFunc {
 	clients := make(map[string]*client)
 	var mu sync.Mutex
 	
-	return func(c *Context) bool {
+	return func(c *Context) {
 		ip := c.IP()
 		now := time.Now()
 		
 		mu.Lock()
-		defer mu.Unlock()
 		
 		// Clean up old entries
 		for k, v := range clients {
@@ -131,10 +138,9 @@ func RateLimit(requestsPerMinute int) MiddlewareFunc {
 		
 		// Check rate limit
 		if cl.requests >= requestsPerMinute {
-			c.Status(429).JSON(map[string]string{
-				"error": "Rate limit exceeded",
-			})
-			return false
+			mu.Unlock()
+			c.app.renderError(c, c.route, 429, NewHTTPError(429, "Rate limit exceeded"))
+			return
 		}
 		
 		cl.requests++
@@ -143,95 +149,309 @@ func RateLimit(requestsPerMinute int) MiddlewareFunc {
 		c.Header("X-RateLimit-Limit", strconv.Itoa(requestsPerMinute))
 		c.Header("X-RateLimit-Remaining", strconv.Itoa(requestsPerMinute-cl.requests))
 		c.Header("X-RateLimit-Reset", strconv.FormatInt(cl.reset.Unix(), 10))
+		mu.Unlock()
 		
-		return true
+		c.Next()
 	}
 }
 
 // Secure returns a middleware that adds security headers
 func Secure() MiddlewareFunc {
-	return func(c *Context) bool {
+	return func(c *Context) {
 		c.Header("X-Content-Type-Options", "nosniff")
 		c.Header("X-Frame-Options", "DENY")
 		c.Header("X-XSS-Protection", "1; mode=block")
 		c.Header("Strict-Transport-Security", "max-age=31536000")
 		c.Header("Content-Security-Policy", "default-src 'self'")
-		return true
+		c.Next()
 	}
 }
 
 // BasicAuth returns a middleware that implements basic authentication
 func BasicAuth(username, password string) MiddlewareFunc {
-	return func(c *Context) bool {
+	return func(c *Context) {
 		user, pass, ok := c.Request.BasicAuth()
 		if !ok || user != username || pass != password {
 			c.Header("WWW-Authenticate", `Basic realm="Restricted"`)
-			c.Status(401).JSON(map[string]string{
-				"error": "Unauthorized",
-			})
-			return false
+			c.app.renderError(c, c.route, 401, NewHTTPError(401, "Unauthorized"))
+			return
 		}
-		return true
+		c.Next()
 	}
 }
 
 // RequireAuth returns a middleware that requires authentication
 func RequireAuth() MiddlewareFunc {
-	return func(c *Context) bool {
+	return func(c *Context) {
 		userID := c.Session().Get("user_id")
 		if userID == nil {
-			c.Status(401).JSON(map[string]string{
-				"error": "Authentication required",
-			})
-			return false
+			c.app.renderError(c, c.route, 401, NewHTTPError(401, "Authentication required"))
+			return
 		}
 		
 		// Store user ID in context for use in handlers
 		c.Set("user_id", userID)
-		return true
+		c.Next()
 	}
 }
 
-// Timeout returns a middleware that implements request timeout
+// Timeout returns a middleware that gives the rest of the chain a deadline.
+// The request context, and so c.Done(), is cancelled once duration has
+// passed; a handler that has not finished by then is answered with 503
+// Service Unavailable. The handler writes into a buffer that is only sent
+// if it finishes in time; later writes fail with http.ErrHandlerTimeout.
+// Streaming routes cannot be buffered: c.SSE returns an error under it.
 func Timeout(duration time.Duration) MiddlewareFunc {
-	return func(c *Context) bool {
-		done := make(chan bool, 1)
-		
-		go func() {
-			// Simulate processing (in real implementation, this would be the actual request handling)
-			done <- true
+	return func(c *Context) {
+		runWithTimeout(c, duration, (*Context).Next)
+	}
+}
+
+// TimeoutHandler gives a single handler a deadline, like Timeout
+//
+//	app.Get("/reports/:id", smallapi.TimeoutHandler(buildReport, 30*time.Second))
+func TimeoutHandler(handler HandlerFunc, duration time.Duration) HandlerFunc {
+	return func(c *Context) {
+		runWithTimeout(c, duration, handler)
+	}
+}
+
+// runWithTimeout runs fn with a deadline on another goroutine, on a copy of
+// the context so that a late handler never touches this request's context,
+// data or writer
+func runWithTimeout(c *Context, duration time.Duration, fn func(*Context)) {
+	request := c.Request
+	ctx, cancel := context.WithTimeout(request.Context(), duration)
+	defer cancel()
+
+	buffer := newBufferedWriter(c.Response.Header())
+	inner := *c
+	inner.Request = request.WithContext(ctx)
+	inner.writer = newResponseWriter(buffer)
+	inner.Response = inner.writer
+
+	// The maps are copied back only if fn finishes in time; a session
+	// already loaded is shared, its methods are safe for concurrent use
+	inner.data = make(map[string]interface{}, len(c.data))
+	for key, value := range c.data {
+		inner.data[key] = value
+	}
+	inner.params = make(map[string]string, len(c.params))
+	for key, value := range c.params {
+		inner.params[key] = value
+	}
+
+	done := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			done <- recover()
 		}()
-		
-		select {
-		case <-done:
-			return true
-		case <-time.After(duration):
-			c.Status(408).JSON(map[string]string{
-				"error": "Request timeout",
-			})
-			return false
+		fn(&inner)
+	}()
+
+	select {
+	case p := <-done:
+		if p != nil {
+			panic(p) // let Recovery or Abort handling deal with it on this goroutine
+		}
+		response, writer, session := c.Response, c.writer, c.session
+		*c = inner
+		c.Request, c.Response, c.writer = request, response, writer
+
+		// A session first loaded by fn is saved when this response, not
+		// the buffer, sends its headers, even if fn wrote nothing
+		if session == nil && c.session != nil {
+			c.session.mutex.Lock()
+			c.session.w = response
+			c.session.mutex.Unlock()
+		}
+		for _, fn := range inner.writer.beforeHeader {
+			writer.onHeader(fn)
 		}
+
+		// With nothing written, the status set with c.Status is sent once
+		// the request is done, as without a timeout
+		buffer.flushTo(response)
+	case <-ctx.Done():
+		buffer.discard()
+		if ctx.Err() != context.DeadlineExceeded {
+			c.err = ctx.Err() // the client went away, there is no one to answer
+			return
+		}
+		c.app.renderError(c, c.route, http.StatusServiceUnavailable,
+			NewHTTPError(http.StatusServiceUnavailable, "Request timed out").Wrap(ctx.Err()))
+	}
+}
+
+// bufferedWriter is a ResponseWriter that holds the response in memory. It
+// does not implement http.Flusher, as nothing may reach the client before
+// the handler finishes.
+type bufferedWriter struct {
+	mu        sync.Mutex
+	header    http.Header
+	body      bytes.Buffer
+	status    int
+	discarded bool
+}
+
+// newBufferedWriter creates a buffer starting with a copy of header
+func newBufferedWriter(header http.Header) *bufferedWriter {
+	return &bufferedWriter{header: header.Clone()}
+}
+
+// Header returns the buffered header map
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader records the status code
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+// Write buffers the body, or drops it once the buffer was discarded
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.discarded {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = 200
+	}
+	return w.body.Write(b)
+}
+
+// discard drops the buffered response and any later writes
+func (w *bufferedWriter) discard() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.discarded = true
+	w.body.Reset()
+}
+
+// flushTo sends the buffered response to dst. Headers are only written
+// if the handler wrote them, so a status set without a body is left to the
+// caller.
+func (w *bufferedWriter) flushTo(dst http.ResponseWriter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	header := dst.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range w.header {
+		header[key] = values
+	}
+
+	if w.status != 0 {
+		dst.WriteHeader(w.status)
+	}
+	if w.body.Len() > 0 {
+		dst.Write(w.body.Bytes())
 	}
 }
 
 // RequestID returns a middleware that adds a unique request ID
 func RequestID() MiddlewareFunc {
-	return func(c *Context) bool {
+	return func(c *Context) {
 		id := fmt.Sprintf("%d", time.Now().UnixNano())
 		c.Header("X-Request-ID", id)
 		c.Set("request_id", id)
-		return true
+		c.Next()
 	}
 }
 
-// Compress returns a middleware that compresses responses
+// Compress returns a middleware that gzips responses for clients that
+// accept it
 func Compress() MiddlewareFunc {
-	return func(c *Context) bool {
+	return func(c *Context) {
 		// Check if client accepts gzip
 		acceptEncoding := c.Request.Header.Get("Accept-Encoding")
-		if strings.Contains(acceptEncoding, "gzip") {
-			c.Header("Content-Encoding", "gzip")
+		if c.Method() == "HEAD" || !strings.Contains(acceptEncoding, "gzip") {
+			c.Next()
+			return
 		}
-		return true
+
+		response := c.Response
+		gw := &gzipResponseWriter{ResponseWriter: response}
+		c.Response = gw
+		defer func() {
+			gw.close()
+			c.Response = response
+		}()
+
+		c.Next()
+	}
+}
+
+// gzipResponseWriter compresses the body written through it. Compression
+// starts with the first write, so empty and bodiless responses stay as is.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+// WriteHeader switches to gzip unless the response is already encoded or
+// cannot have a body
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	header := w.Header()
+	if header.Get("Content-Encoding") == "" && code >= 200 && code != 204 && code != 304 {
+		header.Set("Content-Encoding", "gzip")
+		header.Add("Vary", "Accept-Encoding")
+		header.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write compresses b
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(200)
+	}
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// Flush flushes the compressed data written so far
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets WebSocket upgrades through
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// close writes the gzip footer
+func (w *gzipResponseWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
 	}
 }
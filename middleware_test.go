@@ -0,0 +1,158 @@
+package smallapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutIsolatesLateHandler(t *testing.T) {
+	finished := make(chan struct{})
+	app := New()
+	app.Use(Timeout(10 * time.Millisecond))
+	app.Teardown(func(c *Context, err error) {
+		for i := 0; i < 100; i++ {
+			c.Set("teardown", i)
+			_ = c.Param("id")
+		}
+	})
+	app.Get("/slow/:id", func(c *Context) {
+		defer close(finished)
+		<-c.Done()
+		time.Sleep(10 * time.Millisecond) // let the 503 and teardown run
+		for i := 0; i < 100; i++ {
+			c.Set("handler", i)
+			c.Session().Set("handler", i)
+		}
+		c.String("late")
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/slow/1", nil))
+	<-finished
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+}
+
+func TestTimeoutKeepsDataOfFinishedHandler(t *testing.T) {
+	app := New()
+	app.Use(Timeout(time.Second))
+	var got interface{}
+	app.Teardown(func(c *Context, err error) {
+		got = c.Get("user")
+	})
+	app.Get("/users/:id", func(c *Context) {
+		c.Set("user", c.Param("id"))
+		c.String("ok")
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	if got != "42" {
+		t.Fatalf("teardown saw user %v, want 42", got)
+	}
+}
+
+func TestTimeoutRefusesSSE(t *testing.T) {
+	app := New()
+	var err error
+	app.Get("/events", TimeoutHandler(func(c *Context) {
+		err = c.SSE(func(stream *EventStream) {
+			stream.Data("never buffered")
+		})
+	}, time.Second))
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+	if err == nil {
+		t.Fatal("SSE under Timeout succeeded, want an error")
+	}
+	if ct := w.Header().Get("Content-Type"); ct == "text/event-stream" {
+		t.Fatalf("stream headers were sent: %q", w.Body.String())
+	}
+}
+
+func TestTimeoutStatusWithoutBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler HandlerFunc
+		status  int
+		body    string
+	}{
+		{"no content", func(c *Context) { c.Status(204) }, 204, ""},
+		{"created without body", func(c *Context) { c.Status(201) }, 201, ""},
+		{"created with body", func(c *Context) { c.Status(201).String("made") }, 201, "made"},
+		{"nothing", func(c *Context) {}, 200, ""},
+		{"header only", func(c *Context) { c.Response.WriteHeader(202) }, 202, ""},
+	}
+
+	for _, tt := range tests {
+		for _, wrap := range []string{"Timeout", "TimeoutHandler"} {
+			app := New()
+			if wrap == "Timeout" {
+				app.Use(Timeout(time.Second))
+				app.Get("/", tt.handler)
+			} else {
+				app.Get("/", TimeoutHandler(tt.handler, time.Second))
+			}
+
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != tt.status || w.Body.String() != tt.body {
+				t.Errorf("%s, %s: got %d %q, want %d %q", wrap, tt.name, w.Code, w.Body.String(), tt.status, tt.body)
+			}
+		}
+	}
+}
+
+func TestTimeoutSavesSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler HandlerFunc
+		status  int
+	}{
+		{"no content", func(c *Context) {
+			c.Session().Set("user", "alice")
+			c.Status(204)
+		}, 204},
+		{"with body", func(c *Context) {
+			c.Session().Set("user", "alice")
+			c.String("ok")
+		}, 200},
+	}
+
+	for _, tt := range tests {
+		app := New()
+		app.Use(Timeout(time.Second))
+		app.Get("/login", tt.handler)
+		app.Get("/me", func(c *Context) {
+			user, _ := c.Session().Get("user").(string)
+			c.String(user)
+		})
+
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest("GET", "/login", nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Errorf("%s: got %d cookies, want 1", tt.name, len(cookies))
+			continue
+		}
+
+		r := httptest.NewRequest("GET", "/me", nil)
+		r.AddCookie(cookies[0])
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		if w.Body.String() != "alice" {
+			t.Errorf("%s: session user = %q, want alice", tt.name, w.Body.String())
+		}
+	}
+}
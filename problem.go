@@ -0,0 +1,151 @@
+package smallapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Problem is an RFC 9457 problem details document. It is also an error:
+// a handler returning a *Problem is answered with it as
+// application/problem+json.
+//
+//	return smallapi.NewProblem(403, "Your balance is 30, but that costs 50.").
+//	        WithType("https://example.com/probs/out-of-credit").
+//	        With("balance", 30)
+type Problem struct {
+	Type       string                 `json:"type,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Status     int                    `json:"status,omitempty"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Extensions map[string]interface{} `json:"-"` // extra members, such as "errors"
+}
+
+// NewProblem creates a problem of the default "about:blank" type, titled
+// with the status text
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Error describes the problem
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return fmt.Sprintf("%d %s: %s", p.Status, p.Title, p.Detail)
+	}
+	return fmt.Sprintf("%d %s", p.Status, p.Title)
+}
+
+// WithType returns a copy of the problem with a type URI and title
+// identifying the kind of problem
+func (p *Problem) WithType(typeURI string, title ...string) *Problem {
+	copy := p.clone()
+	copy.Type = typeURI
+	if len(title) > 0 {
+		copy.Title = title[0]
+	}
+	return copy
+}
+
+// With returns a copy of the problem with an extension member
+func (p *Problem) With(key string, value interface{}) *Problem {
+	copy := p.clone()
+	copy.Extensions[key] = value
+	return copy
+}
+
+// clone copies the problem and its extensions
+func (p *Problem) clone() *Problem {
+	copy := *p
+	copy.Extensions = make(map[string]interface{}, len(p.Extensions)+1)
+	for key, value := range p.Extensions {
+		copy.Extensions[key] = value
+	}
+	return &copy
+}
+
+// MarshalJSON writes the extension members next to the standard ones,
+// which they cannot replace
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+
+	standard := map[string]interface{}{
+		"type":     p.Type,
+		"title":    p.Title,
+		"status":   p.Status,
+		"detail":   p.Detail,
+		"instance": p.Instance,
+	}
+	for key, value := range standard {
+		delete(members, key)
+		if value != "" && value != 0 {
+			members[key] = value
+		}
+	}
+
+	return json.Marshal(members)
+}
+
+// ProblemDetails switches the application to RFC 9457 error responses:
+// every error the framework answers - 404, 405, 401 from BasicAuth and
+// RequireUser, 429 from RateLimit, 500 after a panic, returned errors and
+// ValidationErrors - is sent as application/problem+json.
+func (a *App) ProblemDetails(enabled bool) *App {
+	a.problems = enabled
+	return a
+}
+
+// problemFor describes err, answered with status, as a problem
+func problemFor(c *Context, status int, err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		problem = problem.clone()
+	} else {
+		public := publicError(status, err)
+		problem = NewProblem(status, "")
+		if public.Message != http.StatusText(status) {
+			problem.Detail = public.Message
+		}
+		problem.Extensions = make(map[string]interface{})
+		if public.Code != "" {
+			problem.Extensions["code"] = public.Code
+		}
+
+		var validationErrs ValidationErrors
+		if errors.As(err, &validationErrs) {
+			problem.Extensions["errors"] = validationErrs
+		} else if public.Details != nil {
+			problem.Extensions["details"] = public.Details
+		}
+	}
+
+	if problem.Status == 0 {
+		problem.Status = status
+	}
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Title == "" && problem.Type == "about:blank" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	if problem.Instance == "" {
+		problem.Instance = c.Request.URL.RequestURI()
+	}
+	return problem
+}
+
+// sendProblem writes a problem document with its status
+func (c *Context) sendProblem(problem *Problem) error {
+	c.Response.Header().Set("Content-Type", "application/problem+json")
+	c.Response.WriteHeader(problem.Status)
+	return json.NewEncoder(c.Response).Encode(problem)
+}
@@ -0,0 +1,671 @@
+package smallapi
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Renderer writes response payloads in one media type. A renderer that
+// can only handle some values, like CSV for slices of structs, may also
+// implement Supports(v interface{}) bool so Negotiate offers it only for
+// those.
+type Renderer interface {
+	MediaType() string
+	Render(w io.Writer, v interface{}) error
+}
+
+// defaultRenderers are the formats Negotiate offers, in order of preference
+func defaultRenderers() []Renderer {
+	return []Renderer{jsonRenderer{}, xmlRenderer{}, yamlRenderer{}, csvRenderer{}, msgpackRenderer{}}
+}
+
+// Renderer registers a format for Negotiate, replacing the renderer of
+// the same media type. Formats are preferred in the order they were added,
+// after the built-in JSON, XML, YAML, CSV and MessagePack.
+//
+//	app.Renderer(protobufRenderer{})
+func (a *App) Renderer(renderer Renderer) *App {
+	for i, r := range a.renderers {
+		if r.MediaType() == renderer.MediaType() {
+			a.renderers[i] = renderer
+			return a
+		}
+	}
+	a.renderers = append(a.renderers, renderer)
+	return a
+}
+
+// renderer returns the registered renderer for mediaType, or fallback
+func (a *App) renderer(mediaType string, fallback Renderer) Renderer {
+	for _, r := range a.renderers {
+		if r.MediaType() == mediaType {
+			return r
+		}
+	}
+	return fallback
+}
+
+// Negotiate sends v in the format the Accept header prefers among the
+// registered renderers, and as HTML with the named template if one is
+// given. Without an Accept header v is sent as JSON. When no format is
+// acceptable nothing is written and a 406 *HTTPError is returned.
+//
+//	app.Get("/users", smallapi.Handle(func(c *smallapi.Context) error {
+//	        return c.Negotiate(users, "users.html")
+//	}))
+func (c *Context) Negotiate(v interface{}, template ...string) error {
+	var renderers []Renderer
+	for _, r := range c.app.renderers {
+		if supports(r, v) {
+			renderers = append(renderers, r)
+		}
+	}
+	if len(template) > 0 {
+		// HTML comes second, so clients without a preference still get JSON
+		html := templateRenderer{engine: c.app.templates, name: template[0]}
+		if len(renderers) > 0 {
+			renderers = append([]Renderer{renderers[0], html}, renderers[1:]...)
+		} else {
+			renderers = []Renderer{html}
+		}
+	}
+
+	offers := make([]string, len(renderers))
+	for i, r := range renderers {
+		offers[i] = r.MediaType()
+	}
+
+	c.Response.Header().Add("Vary", "Accept")
+	chosen := negotiateType(c.Request.Header.Get("Accept"), offers)
+	for i, offer := range offers {
+		if offer == chosen {
+			return c.render(renderers[i], v)
+		}
+	}
+	return NewHTTPError(http.StatusNotAcceptable, "").WithDetails(map[string][]string{"available": offers})
+}
+
+// XML sends v as an XML response; slices are wrapped in an <items> element
+func (c *Context) XML(v interface{}) error {
+	return c.render(c.app.renderer("application/xml", xmlRenderer{}), v)
+}
+
+// YAML sends v as a YAML response, using the JSON names of struct fields
+func (c *Context) YAML(v interface{}) error {
+	return c.render(c.app.renderer("application/yaml", yamlRenderer{}), v)
+}
+
+// CSV sends a struct or a slice of structs as a CSV response with a header
+// row. Columns are named by the csv or json tags of the fields.
+func (c *Context) CSV(v interface{}) error {
+	return c.render(c.app.renderer("text/csv", csvRenderer{}), v)
+}
+
+// render renders v before writing anything, so a failure can still be
+// answered with an error
+func (c *Context) render(renderer Renderer, v interface{}) error {
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, v); err != nil {
+		return err
+	}
+
+	c.Response.Header().Set("Content-Type", renderer.MediaType())
+	if c.statusCode != 200 {
+		c.Response.WriteHeader(c.statusCode)
+	}
+	_, err := c.Response.Write(buf.Bytes())
+	return err
+}
+
+// supports reports whether renderer can render v
+func supports(renderer Renderer, v interface{}) bool {
+	if checker, ok := renderer.(interface{ Supports(v interface{}) bool }); ok {
+		return checker.Supports(v)
+	}
+	return true
+}
+
+// jsonRenderer renders JSON
+type jsonRenderer struct{}
+
+func (jsonRenderer) MediaType() string { return "application/json" }
+
+func (jsonRenderer) Render(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// templateRenderer renders HTML with a template of the application
+type templateRenderer struct {
+	engine *TemplateEngine
+	name   string
+}
+
+func (r templateRenderer) MediaType() string { return "text/html" }
+
+func (r templateRenderer) Render(w io.Writer, v interface{}) error {
+	output, err := r.engine.Render(r.name, v)
+	if err != nil {
+		return fmt.Errorf("template error: %v", err)
+	}
+	_, err = io.WriteString(w, output)
+	return err
+}
+
+// xmlRenderer renders XML with encoding/xml, which cannot encode maps
+type xmlRenderer struct{}
+
+func (xmlRenderer) MediaType() string { return "application/xml" }
+
+func (xmlRenderer) Supports(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	typ := reflect.TypeOf(v)
+	return xmlNamed(typ) && xmlEncodable(typ, map[reflect.Type]bool{})
+}
+
+// xmlNamed reports whether encoding/xml can name the elements of a value
+// of typ, which Render writes as one element, or one per item of a slice:
+// unnamed types such as anonymous structs need an XMLName field with a
+// tag. Interfaces are assumed to hold named values.
+func xmlNamed(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch {
+	case (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) && typ.Elem().Kind() != reflect.Uint8:
+		return xmlNamed(typ.Elem())
+	case typ.Name() != "" || typ.Kind() == reflect.Interface:
+		return true
+	case typ.Kind() == reflect.Struct:
+		field, ok := typ.FieldByName("XMLName")
+		return ok && field.Type == xmlNameType && strings.Split(field.Tag.Get("xml"), ",")[0] != ""
+	}
+	return false
+}
+
+// xmlEncodable reports whether values of typ contain no maps, which
+// encoding/xml rejects; interfaces are assumed to hold encodable values
+func xmlEncodable(typ reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[typ] {
+		return true
+	}
+	seen[typ] = true
+
+	switch typ.Kind() {
+	case reflect.Map, reflect.Func, reflect.Chan:
+		return false
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return xmlEncodable(typ.Elem(), seen)
+	case reflect.Struct:
+		if reflect.PointerTo(typ).Implements(xmlMarshalerType) || typ.Implements(textMarshalerType) {
+			return true
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" || field.Tag.Get("xml") == "-" {
+				continue
+			}
+			if !xmlEncodable(field.Type, seen) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var (
+	xmlMarshalerType  = reflect.TypeOf((*xml.Marshaler)(nil)).Elem()
+	xmlNameType       = reflect.TypeOf(xml.Name{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (xmlRenderer) Render(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+
+	val := indirect(reflect.ValueOf(v))
+	if (val.Kind() == reflect.Slice || val.Kind() == reflect.Array) && val.Type().Elem().Kind() != reflect.Uint8 {
+		items := xml.StartElement{Name: xml.Name{Local: "items"}}
+		if err := enc.EncodeToken(items); err != nil {
+			return err
+		}
+		for i := 0; i < val.Len(); i++ {
+			if err := enc.Encode(val.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		if err := enc.EncodeToken(items.End()); err != nil {
+			return err
+		}
+		return enc.Flush()
+	}
+	return enc.Encode(v)
+}
+
+// csvRenderer renders a struct or a slice of structs as CSV
+type csvRenderer struct{}
+
+func (csvRenderer) MediaType() string { return "text/csv" }
+
+func (csvRenderer) Supports(v interface{}) bool {
+	_, ok := csvRows(v)
+	return ok
+}
+
+func (csvRenderer) Render(w io.Writer, v interface{}) error {
+	rows, ok := csvRows(v)
+	if !ok {
+		return fmt.Errorf("csv: cannot render %T, only structs and slices of structs", v)
+	}
+
+	var structType reflect.Type
+	if len(rows) > 0 {
+		structType = rows[0].Type()
+	} else {
+		structType = indirectType(indirect(reflect.ValueOf(v)).Type().Elem())
+	}
+	columns := csvColumns(structType)
+
+	out := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.name
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, column := range columns {
+			cell, err := csvCell(row.FieldByIndex(column.index))
+			if err != nil {
+				return err
+			}
+			record[i] = cell
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvColumn is a struct field written as a CSV column
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+// csvColumns lists the exported fields of a struct type, named by their
+// csv or json tags; fields tagged "-" are left out
+func csvColumns(typ reflect.Type) []csvColumn {
+	var columns []csvColumn
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("csv"), ",")
+		if name == "" {
+			if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == "-" {
+				continue
+			}
+			name = jsonFieldName(field)
+		}
+		if name == "-" {
+			continue
+		}
+		columns = append(columns, csvColumn{name: name, index: field.Index})
+	}
+	return columns
+}
+
+// csvRows returns the structs of a struct or a slice of structs, possibly
+// behind pointers
+func csvRows(v interface{}) ([]reflect.Value, bool) {
+	val := indirect(reflect.ValueOf(v))
+	switch {
+	case val.Kind() == reflect.Struct:
+		return []reflect.Value{val}, true
+	case val.Kind() == reflect.Slice || val.Kind() == reflect.Array:
+		if indirectType(val.Type().Elem()).Kind() != reflect.Struct {
+			return nil, false
+		}
+		rows := make([]reflect.Value, 0, val.Len())
+		for i := 0; i < val.Len(); i++ {
+			if row := indirect(val.Index(i)); row.IsValid() {
+				rows = append(rows, row)
+			}
+		}
+		return rows, true
+	}
+	return nil, false
+}
+
+// csvCell formats a field as text: strings and numbers as they are, text
+// marshalers like time.Time as their text and anything else as JSON
+func csvCell(field reflect.Value) (string, error) {
+	field = indirect(field)
+	if !field.IsValid() {
+		return "", nil
+	}
+	if marshaler, ok := field.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+
+	switch field.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(field.Interface()), nil
+	}
+	data, err := json.Marshal(field.Interface())
+	return string(data), err
+}
+
+// indirect follows pointers and interfaces; nil gives the zero Value
+func indirect(val reflect.Value) reflect.Value {
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return reflect.Value{}
+		}
+		val = val.Elem()
+	}
+	return val
+}
+
+// indirectType follows pointer types
+func indirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// yamlRenderer renders YAML. Values are first encoded as JSON, so they use
+// the same field names and marshalers as JSON responses.
+type yamlRenderer struct{}
+
+func (yamlRenderer) MediaType() string { return "application/yaml" }
+
+func (yamlRenderer) Render(w io.Writer, v interface{}) error {
+	value, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	writeYAML(&buf, value, 0)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// writeYAML writes value as a block at the indent level, starting at the
+// beginning of a line
+func writeYAML(buf *bytes.Buffer, value interface{}, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch value := value.(type) {
+	case *orderedMap:
+		if len(value.keys) == 0 {
+			buf.WriteString(pad + "{}\n")
+			return
+		}
+		for i, key := range value.keys {
+			buf.WriteString(pad + yamlScalar(key) + ":")
+			writeYAMLChild(buf, value.values[i], indent)
+		}
+	case []interface{}:
+		if len(value) == 0 {
+			buf.WriteString(pad + "[]\n")
+			return
+		}
+		for _, item := range value {
+			buf.WriteString(pad + "-")
+			writeYAMLChild(buf, item, indent)
+		}
+	default:
+		buf.WriteString(pad + yamlScalar(value) + "\n")
+	}
+}
+
+// writeYAMLChild writes the value following a "key:" or "-": scalars and
+// empty collections on the same line, anything else on the next ones
+func writeYAMLChild(buf *bytes.Buffer, value interface{}, indent int) {
+	switch v := value.(type) {
+	case *orderedMap:
+		if len(v.keys) > 0 {
+			buf.WriteString("\n")
+			writeYAML(buf, v, indent+1)
+			return
+		}
+		buf.WriteString(" {}\n")
+	case []interface{}:
+		if len(v) > 0 {
+			buf.WriteString("\n")
+			writeYAML(buf, v, indent+1)
+			return
+		}
+		buf.WriteString(" []\n")
+	default:
+		buf.WriteString(" " + yamlScalar(value) + "\n")
+	}
+}
+
+// yamlPlain matches strings that can be written without quotes
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_./@+-]*( [A-Za-z0-9_./@+-]+)*$`)
+
+// yamlReserved are plain words YAML would read as something else
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true, ".inf": true, ".nan": true,
+}
+
+// yamlScalar formats a scalar; strings that need it are double-quoted,
+// with JSON escapes that YAML shares
+func yamlScalar(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if value {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return value.String()
+	case string:
+		if yamlPlain.MatchString(value) && !yamlReserved[strings.ToLower(value)] {
+			return value
+		}
+		quoted, _ := json.Marshal(value)
+		return string(quoted)
+	}
+	return fmt.Sprint(value)
+}
+
+// msgpackRenderer renders MessagePack. Like YAML, values are first encoded
+// as JSON.
+type msgpackRenderer struct{}
+
+func (msgpackRenderer) MediaType() string { return "application/msgpack" }
+
+func (msgpackRenderer) Render(w io.Writer, v interface{}) error {
+	value, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := writeMsgpack(&buf, value); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// writeMsgpack encodes value in the smallest MessagePack form
+func writeMsgpack(buf *bytes.Buffer, value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if value {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			writeMsgpackInt(buf, n)
+			return nil
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(0xcb)
+		writeBigEndian(buf, math.Float64bits(f), 8)
+	case string:
+		writeMsgpackHeader(buf, len(value), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		buf.WriteString(value)
+	case []interface{}:
+		writeMsgpackHeader(buf, len(value), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range value {
+			if err := writeMsgpack(buf, item); err != nil {
+				return err
+			}
+		}
+	case *orderedMap:
+		writeMsgpackHeader(buf, len(value.keys), 0x80, 16, 0, 0xde, 0xdf)
+		for i, key := range value.keys {
+			writeMsgpack(buf, key)
+			if err := writeMsgpack(buf, value.values[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot encode %T", value)
+	}
+	return nil
+}
+
+// writeMsgpackHeader writes the type and length of a string, array or map:
+// a fix form below fixLimit, then 8-bit (if the type has one), 16-bit and
+// 32-bit lengths
+func writeMsgpackHeader(buf *bytes.Buffer, n int, fix byte, fixLimit int, code8, code16, code32 byte) {
+	switch {
+	case n < fixLimit:
+		buf.WriteByte(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		buf.WriteByte(code8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		writeBigEndian(buf, uint64(n), 2)
+	default:
+		buf.WriteByte(code32)
+		writeBigEndian(buf, uint64(n), 4)
+	}
+}
+
+// writeMsgpackInt writes an integer as a fixint or the smallest int type
+func writeMsgpackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n < 128, n >= -32 && n < 0:
+		buf.WriteByte(byte(n))
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(n))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		writeBigEndian(buf, uint64(n), 2)
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		writeBigEndian(buf, uint64(n), 4)
+	default:
+		buf.WriteByte(0xd3)
+		writeBigEndian(buf, uint64(n), 8)
+	}
+}
+
+// writeBigEndian writes the low size bytes of n, most significant first
+func writeBigEndian(buf *bytes.Buffer, n uint64, size int) {
+	for i := size - 1; i >= 0; i-- {
+		buf.WriteByte(byte(n >> (8 * i)))
+	}
+}
+
+// orderedMap is a JSON object that keeps the order of its keys
+type orderedMap struct {
+	keys   []string
+	values []interface{}
+}
+
+// toGeneric converts v through JSON into nil, bool, json.Number, string,
+// []interface{} and *orderedMap values, keeping struct field order
+func toGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return decodeGeneric(dec)
+}
+
+// decodeGeneric decodes the next JSON value from dec
+func decodeGeneric(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := &orderedMap{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeGeneric(dec)
+			if err != nil {
+				return nil, err
+			}
+			object.keys = append(object.keys, key.(string))
+			object.values = append(object.values, value)
+		}
+		_, err := dec.Token()
+		return object, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeGeneric(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token()
+		return list, err
+	case json.Delim('}'), json.Delim(']'):
+		return nil, errors.New("unexpected end of JSON value")
+	}
+	return token, nil
+}
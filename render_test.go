@@ -0,0 +1,274 @@
+package smallapi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type renderUser struct {
+	ID   int      `json:"id" xml:"id,attr"`
+	Name string   `json:"name" xml:"name"`
+	Tags []string `json:"tags,omitempty" xml:"tag"`
+}
+
+type renderUsers []renderUser
+
+type renderRows []struct{ Name string }
+
+type renderEvent struct {
+	Name string    `csv:"event" json:"name"`
+	At   time.Time `json:"at"`
+	Note *string   `json:"note"`
+	Skip string    `json:"-"`
+	Meta map[string]int
+}
+
+// renderString renders v with renderer or fails the test
+func renderString(t *testing.T, renderer Renderer, v interface{}) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, v); err != nil {
+		t.Fatalf("%T: %v", v, err)
+	}
+	return buf.String()
+}
+
+func TestXMLRenderer(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"struct", renderUser{ID: 1, Name: "Ann", Tags: []string{"a", "b"}},
+			`<renderUser id="1"><name>Ann</name><tag>a</tag><tag>b</tag></renderUser>`},
+		{"pointer", &renderUser{ID: 2, Name: "Bo"}, `<renderUser id="2"><name>Bo</name></renderUser>`},
+		{"slice", []renderUser{{ID: 1, Name: "Ann"}, {ID: 2, Name: "Bo"}},
+			`<items><renderUser id="1"><name>Ann</name></renderUser><renderUser id="2"><name>Bo</name></renderUser></items>`},
+		{"named slice", renderUsers{{ID: 1, Name: "Ann"}}, `<items><renderUser id="1"><name>Ann</name></renderUser></items>`},
+		{"empty slice", []renderUser{}, `<items></items>`},
+		{"scalars", []int{1, 2}, `<items><int>1</int><int>2</int></items>`},
+		{"escaping", "<a & b>", `<string>&lt;a &amp; b&gt;</string>`},
+		{"XMLName", struct {
+			XMLName xml.Name `xml:"user"`
+			Name    string   `xml:"name"`
+		}{Name: "Ann"}, `<user><name>Ann</name></user>`},
+	}
+
+	for _, tt := range tests {
+		if !(xmlRenderer{}).Supports(tt.v) {
+			t.Errorf("%s: not supported", tt.name)
+			continue
+		}
+		if got := renderString(t, xmlRenderer{}, tt.v); got != xml.Header+tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, xml.Header+tt.want)
+		}
+	}
+}
+
+func TestXMLRendererSupports(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want bool
+	}{
+		{"struct", renderUser{}, true},
+		{"slice of pointers", []*renderUser{}, true},
+		{"interfaces", []interface{}{renderUser{}}, true},
+		{"nil", nil, false},
+		{"map", map[string]int{}, false},
+		{"struct with a map", renderEvent{}, false},
+		{"slice of maps", []map[string]interface{}{}, false},
+		{"anonymous struct", struct{ Name string }{}, false},
+		{"pointer to anonymous struct", &struct{ Name string }{}, false},
+		{"slice of anonymous structs", []struct{ Name string }{}, false},
+		{"named slice of anonymous structs", renderRows{}, false},
+		{"nested slices of anonymous structs", [][]struct{ Name string }{}, false},
+		{"untagged XMLName", struct {
+			XMLName xml.Name
+			Name    string
+		}{}, false},
+		{"bytes", []byte("a"), false},
+		{"func", func() {}, false},
+	}
+
+	for _, tt := range tests {
+		if got := (xmlRenderer{}).Supports(tt.v); got != tt.want {
+			t.Errorf("%s: Supports = %v, want %v", tt.name, got, tt.want)
+		}
+		// Whatever is supported must render
+		if tt.want {
+			renderString(t, xmlRenderer{}, tt.v)
+		}
+	}
+}
+
+func TestYAMLRenderer(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"struct", renderUser{ID: 1, Name: "Ann", Tags: []string{"a", "b c"}},
+			"id: 1\nname: Ann\ntags:\n  - a\n  - b c\n"},
+		{"slice", []renderUser{{ID: 1, Name: "Ann"}, {ID: 2, Name: "Bo"}},
+			"-\n  id: 1\n  name: Ann\n-\n  id: 2\n  name: Bo\n"},
+		{"empty collections", map[string]interface{}{"list": []int{}, "map": map[string]int{}},
+			"list: []\nmap: {}\n"},
+		{"quoted strings", []interface{}{"yes", "1", "a: b", "", "line\nbreak", " pad"},
+			"- \"yes\"\n- \"1\"\n- \"a: b\"\n- \"\"\n- \"line\\nbreak\"\n- \" pad\"\n"},
+		{"scalars", []interface{}{nil, true, 1.5, -3},
+			"- null\n- true\n- 1.5\n- -3\n"},
+		{"nested", map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{map[string]int{"c": 1}}}},
+			"a:\n  b:\n    -\n      c: 1\n"},
+	}
+
+	for _, tt := range tests {
+		if got := renderString(t, yamlRenderer{}, tt.v); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCSVRenderer(t *testing.T) {
+	note := "late, again"
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"struct", renderUser{ID: 1, Name: "Ann", Tags: []string{"a"}},
+			"id,name,tags\n1,Ann,\"[\"\"a\"\"]\"\n"},
+		{"slice of pointers", []*renderUser{{ID: 1, Name: "Ann"}, nil, {ID: 2, Name: "Bo"}},
+			"id,name,tags\n1,Ann,null\n2,Bo,null\n"},
+		{"empty slice", []renderUser{}, "id,name,tags\n"},
+		{"tags and marshalers", []renderEvent{{Name: "deploy", At: at, Note: &note}, {Name: "rollback", At: at}},
+			"event,at,note,Meta\ndeploy,2024-05-01T12:00:00Z,\"late, again\",null\nrollback,2024-05-01T12:00:00Z,,null\n"},
+	}
+
+	for _, tt := range tests {
+		if got := renderString(t, csvRenderer{}, tt.v); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+
+	for _, v := range []interface{}{[]int{1}, "text", map[string]int{}} {
+		if (csvRenderer{}).Supports(v) {
+			t.Errorf("%T: supported", v)
+		}
+	}
+}
+
+func TestMsgpackRenderer(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want []byte
+	}{
+		{"nil", nil, []byte{0xc0}},
+		{"bools", []bool{true, false}, []byte{0x92, 0xc3, 0xc2}},
+		{"fixints", []int{0, 127, -1, -32}, []byte{0x94, 0x00, 0x7f, 0xff, 0xe0}},
+		{"int8", -33, []byte{0xd0, 0xdf}},
+		{"int16", 200, []byte{0xd1, 0x00, 0xc8}},
+		{"int32", 70000, []byte{0xd2, 0x00, 0x01, 0x11, 0x70}},
+		{"int64", int64(1) << 40, []byte{0xd3, 0, 0, 0x01, 0, 0, 0, 0, 0}},
+		{"float", 1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"fixstr", "hi", []byte{0xa2, 'h', 'i'}},
+		{"str8", strings.Repeat("x", 32), append([]byte{0xd9, 32}, strings.Repeat("x", 32)...)},
+		{"struct", renderUser{ID: 1, Name: "Ann"},
+			[]byte{0x82, 0xa2, 'i', 'd', 0x01, 0xa4, 'n', 'a', 'm', 'e', 0xa3, 'A', 'n', 'n'}},
+		{"array16", make([]int, 16), append([]byte{0xdc, 0x00, 0x10}, make([]byte, 16)...)},
+	}
+
+	for _, tt := range tests {
+		if got := renderString(t, msgpackRenderer{}, tt.v); got != string(tt.want) {
+			t.Errorf("%s:\n got % x\nwant % x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	anonymous := []struct{ Name string }{{"Ann"}}
+	tests := []struct {
+		name   string
+		v      interface{}
+		accept string
+		status int
+		typ    string
+	}{
+		{"no header", renderUser{}, "", 200, "application/json"},
+		{"xml", renderUser{}, "application/xml", 200, "application/xml"},
+		{"q-values", renderUser{}, "application/json;q=0.5, application/yaml", 200, "application/yaml"},
+		{"csv", []renderUser{}, "text/csv", 200, "text/csv"},
+		{"msgpack", renderUser{}, "application/msgpack", 200, "application/msgpack"},
+		{"map as xml", map[string]int{}, "application/xml", 406, ""},
+		{"anonymous structs as xml", anonymous, "application/xml", 406, ""},
+		{"anonymous structs fall back", anonymous, "application/xml, application/json;q=0.1", 200, "application/json"},
+		{"anonymous structs as csv", anonymous, "text/csv", 200, "text/csv"},
+		{"nothing acceptable", renderUser{}, "image/png", 406, ""},
+	}
+
+	for _, tt := range tests {
+		app := New()
+		app.Get("/", Handle(func(c *Context) error {
+			return c.Negotiate(tt.v)
+		}))
+
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+		if typ := w.Header().Get("Content-Type"); tt.typ != "" && typ != tt.typ {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.name, typ, tt.typ)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("%s: Vary = %q", tt.name, vary)
+		}
+	}
+}
+
+func TestNegotiateTemplate(t *testing.T) {
+	app := New()
+	app.templates.templates["user"] = template.Must(template.New("user").Parse("<p>{{.Name}}</p>"))
+	app.Get("/", Handle(func(c *Context) error {
+		return c.Negotiate(renderUser{Name: "Ann"}, "user")
+	}))
+
+	tests := []struct {
+		accept, typ string
+	}{
+		{"", "application/json"},
+		{"text/html", "text/html"},
+		{"text/html, */*;q=0.8", "text/html"},
+		{"*/*", "application/json"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		if typ := w.Header().Get("Content-Type"); typ != tt.typ {
+			t.Errorf("Accept %q: Content-Type = %q, want %q", tt.accept, typ, tt.typ)
+		}
+		if tt.typ == "text/html" && w.Body.String() != "<p>Ann</p>" {
+			t.Errorf("Accept %q: body = %q", tt.accept, w.Body)
+		}
+		if w.Code != http.StatusOK {
+			t.Errorf("Accept %q: status = %d", tt.accept, w.Code)
+		}
+	}
+}
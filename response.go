@@ -0,0 +1,172 @@
+package smallapi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseWriter wraps the http.ResponseWriter of a request and records
+// what has been sent: the status code, the number of body bytes, the time
+// to first byte and whether the headers were written or the connection
+// hijacked. It is the writer behind Context.Response, so writes made by
+// handlers, http.ServeFile, http.Redirect or wrapped net/http handlers are
+// all tracked.
+type ResponseWriter struct {
+	http.ResponseWriter
+
+	status    int
+	size      int64
+	start     time.Time
+	firstByte time.Duration
+	written   bool
+	hijacked  bool
+
+	beforeHeader []func() // run once, just before the headers are sent
+}
+
+// newResponseWriter wraps w, starting the time-to-first-byte clock
+func newResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w, start: time.Now()}
+}
+
+// WriteHeader sends the response headers with the given status code.
+// Informational 1xx codes are passed through; after the headers have been
+// written, further calls are ignored.
+func (w *ResponseWriter) WriteHeader(code int) {
+	if w.written {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.runBeforeHeader()
+	w.status = code
+	w.written = true
+	w.firstByte = time.Since(w.start)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// onHeader registers fn to run just before the headers are sent, while it
+// can still set headers such as cookies
+func (w *ResponseWriter) onHeader(fn func()) {
+	w.beforeHeader = append(w.beforeHeader, fn)
+}
+
+// runBeforeHeader runs the functions registered with onHeader
+func (w *ResponseWriter) runBeforeHeader() {
+	fns := w.beforeHeader
+	w.beforeHeader = nil
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// Write writes the body, sending a 200 header first if none was written
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// ReadFrom copies r into the body, using the underlying writer's
+// ReadFrom (sendfile for plain connections) when it has one
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(writerOnly{w.ResponseWriter}, r)
+	}
+	w.size += n
+	return n, err
+}
+
+// Flush sends any buffered data to the client
+func (w *ResponseWriter) Flush() {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// canFlush reports whether Flush reaches the client, which it does not
+// when the response is buffered, as under Timeout
+func (w *ResponseWriter) canFlush() bool {
+	_, ok := w.ResponseWriter.(http.Flusher)
+	return ok
+}
+
+// Hijack takes over the connection, e.g. for WebSockets
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	if !w.written {
+		w.runBeforeHeader()
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		w.hijacked = true
+		if !w.written {
+			w.status = http.StatusSwitchingProtocols
+			w.written = true
+			w.firstByte = time.Since(w.start)
+		}
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the status code sent, or 0 if no header was written yet
+func (w *ResponseWriter) Status() int {
+	return w.status
+}
+
+// Size returns the number of body bytes written
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
+
+// Written reports whether the headers have been sent
+func (w *ResponseWriter) Written() bool {
+	return w.written
+}
+
+// Hijacked reports whether the connection was hijacked
+func (w *ResponseWriter) Hijacked() bool {
+	return w.hijacked
+}
+
+// TimeToFirstByte returns how long it took to send the headers, or 0 if
+// they have not been sent yet
+func (w *ResponseWriter) TimeToFirstByte() time.Duration {
+	return w.firstByte
+}
+
+// writerOnly hides the ReadFrom method of a writer so io.Copy does not
+// call back into ResponseWriter.ReadFrom
+type writerOnly struct {
+	io.Writer
+}
@@ -1,7 +1,15 @@
 package smallapi
 
 import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
 	"strings"
+	"text/tabwriter"
 )
 
 // HandlerFunc defines the handler function signature
@@ -11,8 +19,21 @@ type HandlerFunc func(*Context)
 type Route struct {
 	Method  string
 	Path    string
+	Host    string // optional host pattern, e.g. "api.example.com" or ":tenant.example.com"
+	Name    string // optional, used for reverse URL building
 	Handler HandlerFunc
 	Pattern *RoutePattern
+
+	group *RouteGroup // group the route was registered on, if any
+}
+
+// Middleware returns the group middleware that runs before the route's
+// handler, outermost group first
+func (r *Route) Middleware() []MiddlewareFunc {
+	if r.group == nil {
+		return nil
+	}
+	return r.group.chain()
 }
 
 // RoutePattern represents a compiled route pattern
@@ -22,53 +43,255 @@ type RoutePattern struct {
 
 // Segment represents a part of a route pattern
 type Segment struct {
-	IsParam bool
-	Name    string
-	Value   string
+	IsParam    bool
+	IsWildcard bool   // catch-all "*name", captures the rest of the path
+	Optional   bool   // ":name?", may be absent from the request path
+	Constraint string // ":name<int>" or ":name<[a-z-]+>", restricts the values a parameter matches
+	Name       string
+	Value      string
+
+	match func(string) bool
+}
+
+// ParamNames returns the names of the parameter and wildcard segments in order
+func (p *RoutePattern) ParamNames() []string {
+	var names []string
+	for _, segment := range p.Segments {
+		if segment.IsParam || segment.IsWildcard {
+			names = append(names, segment.Name)
+		}
+	}
+	return names
+}
+
+// Variants expands optional segments into every concrete segment list the
+// pattern can match, from the longest to the shortest
+func (p *RoutePattern) Variants() [][]Segment {
+	variants := [][]Segment{nil}
+	for _, segment := range p.Segments {
+		next := make([][]Segment, 0, len(variants)*2)
+		for _, variant := range variants {
+			with := append(append([]Segment(nil), variant...), segment)
+			next = append(next, with)
+			if segment.Optional {
+				next = append(next, variant)
+			}
+		}
+		variants = next
+	}
+	return variants
 }
 
-// Router handles routing for the application
+// Router handles routing for the application.
+// Routes are stored in one compressed radix tree per HTTP method, with a
+// separate set of trees for each host pattern.
 type Router struct {
-	routes []Route
+	routes []*Route
+	trees  map[string]*node // routes for any host
+	hosts  []*hostTrees     // host-specific routes, exact hosts first
+	names  map[string]*Route
 	docs   *Documentation
+
+	strict    bool
+	conflicts []*RouteConflict
+}
+
+// RouteConflict describes a route that an earlier route with the same
+// method and host shadows: an equivalent pattern makes it unreachable, or
+// a parameter with a different constraint accepting some of the same
+// values takes those paths first. Partial conflicts only affect some of
+// the route's optional variants or values. Other overlaps, such as a
+// parameter and a catch-all, are resolved by precedence and are not
+// conflicts.
+type RouteConflict struct {
+	Route    *Route
+	Existing *Route
+	Variant  string // the conflicting variant, in OpenAPI form
+	Partial  bool
+	Path     string // for overlapping constraints, a path Existing takes
+}
+
+// Error describes the conflict
+func (c *RouteConflict) Error() string {
+	host := ""
+	if c.Route.Host != "" {
+		host = " on " + c.Route.Host
+	}
+	if c.Path != "" {
+		return fmt.Sprintf("route %s %s%s overlaps %s %s: both accept paths like %s, which go to the earlier route",
+			c.Route.Method, c.Route.Path, host, c.Existing.Method, c.Existing.Path, c.Path)
+	}
+
+	what := "is unreachable"
+	if c.Partial {
+		what = "is partially shadowed"
+	}
+	return fmt.Sprintf("route %s %s%s %s: %s is already handled by %s %s",
+		c.Route.Method, c.Route.Path, host, what, c.Variant, c.Existing.Method, c.Existing.Path)
 }
 
 // NewRouter creates a new router
 func NewRouter() *Router {
 	return &Router{
-		routes: make([]Route, 0),
+		routes: make([]*Route, 0),
+		trees:  make(map[string]*node),
+		names:  make(map[string]*Route),
 		docs:   NewDocumentation(),
 	}
 }
 
-// Add adds a new route to the router
-func (r *Router) Add(method, path string, handler HandlerFunc) {
-	pattern := r.compilePattern(path)
-	route := Route{
+// Add adds a new route to the router and returns it
+func (r *Router) Add(method, path string, handler HandlerFunc) *Route {
+	return r.AddHost("", method, path, handler)
+}
+
+// AddHost adds a route that only matches requests for the given host
+// pattern; an empty host matches any host
+func (r *Router) AddHost(host, method, path string, handler HandlerFunc) *Route {
+	pattern := compilePattern(path)
+	route := &Route{
 		Method:  method,
 		Path:    path,
+		Host:    host,
 		Handler: handler,
 		Pattern: pattern,
 	}
 	r.routes = append(r.routes, route)
+
+	trees := r.trees
+	if host != "" {
+		trees = r.hostTrees(host).trees
+	}
+
+	root := trees[method]
+	if root == nil {
+		root = &node{}
+		trees[method] = root
+	}
+	// Detect variants that an earlier route already terminates
+	var conflict *RouteConflict
+	reachable := false
+	for _, segments := range pattern.Variants() {
+		existing := root.insert(segments, route)
+		if existing == nil {
+			reachable = true
+			continue
+		}
+		if conflict == nil {
+			conflict = &RouteConflict{Route: route, Existing: existing, Variant: openAPIPath(segments)}
+		}
+	}
+	if conflict != nil {
+		conflict.Partial = reachable
+	}
+
+	// Then values taken by an earlier, differently constrained parameter
+	for _, segments := range pattern.Variants() {
+		if conflict != nil {
+			break
+		}
+		if existing, path := root.overlap(segments); existing != nil {
+			conflict = &RouteConflict{Route: route, Existing: existing, Variant: openAPIPath(segments), Partial: true, Path: path}
+		}
+	}
+
+	if conflict != nil {
+		r.conflicts = append(r.conflicts, conflict)
+		if r.strict {
+			panic("smallapi: " + conflict.Error())
+		}
+		log.Printf("smallapi: warning: %v", conflict)
+	}
+
 	r.docs.AddRoute(method, path, handler)
+	return route
+}
+
+// SetStrict makes the router panic when an earlier route shadows a new
+// one instead of logging a warning
+func (r *Router) SetStrict(strict bool) {
+	r.strict = strict
+}
+
+// Conflicts returns the conflicts detected while registering routes
+func (r *Router) Conflicts() []*RouteConflict {
+	return r.conflicts
+}
+
+// hostTrees returns the trees for a host pattern, creating them if needed
+func (r *Router) hostTrees(host string) *hostTrees {
+	for _, h := range r.hosts {
+		if h.pattern.Host == host {
+			return h
+		}
+	}
+
+	h := &hostTrees{
+		pattern: compileHostPattern(host),
+		trees:   make(map[string]*node),
+	}
+
+	// Exact hosts are tried before host patterns with parameters
+	i := len(r.hosts)
+	if h.pattern.exact() {
+		for i > 0 && !r.hosts[i-1].pattern.exact() {
+			i--
+		}
+	}
+	r.hosts = append(r.hosts, nil)
+	copy(r.hosts[i+1:], r.hosts[i:])
+	r.hosts[i] = h
+
+	return h
 }
 
-// compilePattern compiles a route pattern like "/users/:id/posts/:postId"
-func (r *Router) compilePattern(path string) *RoutePattern {
+// Name registers route under name for reverse URL building. The same name
+// may be shared by routes with the same path (e.g. GET and POST /login).
+func (r *Router) Name(route *Route, name string) {
+	if existing, ok := r.names[name]; ok && existing.Path != route.Path {
+		panic(fmt.Sprintf("smallapi: route name %q already used for %q", name, existing.Path))
+	}
+	route.Name = name
+	if _, ok := r.names[name]; !ok {
+		r.names[name] = route
+	}
+}
+
+// Named returns the route registered under name, or nil
+func (r *Router) Named(name string) *Route {
+	return r.names[name]
+}
+
+// compilePattern compiles a route pattern like "/users/:id/posts/:postId".
+// A ":name?" segment is optional, ":name<type>" only matches values of a
+// built-in parameter type or a regular expression, and a final "*name"
+// segment captures the remainder of the path ("*" alone is captured under
+// the name "path").
+func compilePattern(path string) *RoutePattern {
 	segments := strings.Split(strings.Trim(path, "/"), "/")
 	pattern := &RoutePattern{
 		Segments: make([]Segment, len(segments)),
 	}
 
 	for i, segment := range segments {
-		if strings.HasPrefix(segment, ":") {
+		switch {
+		case strings.HasPrefix(segment, ":"):
 			// Parameter segment
+			pattern.Segments[i] = compileParam(segment, path)
+		case strings.HasPrefix(segment, "*"):
+			// Catch-all segment
+			if i != len(segments)-1 {
+				panic(fmt.Sprintf("smallapi: catch-all %q must be the last segment in %q", segment, path))
+			}
+			name := segment[1:]
+			if name == "" {
+				name = "path"
+			}
 			pattern.Segments[i] = Segment{
-				IsParam: true,
-				Name:    segment[1:], // Remove the ":"
+				IsWildcard: true,
+				Name:       name,
 			}
-		} else {
+		default:
 			// Static segment
 			pattern.Segments[i] = Segment{
 				IsParam: false,
@@ -80,57 +303,214 @@ func (r *Router) compilePattern(path string) *RoutePattern {
 	return pattern
 }
 
-// Match finds a matching route for the given method and path
-func (r *Router) Match(method, path string) (HandlerFunc, map[string]string) {
-	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+// compileParam compiles a parameter segment like ":id", ":id?" or ":id<int>"
+func compileParam(segment, pattern string) Segment {
+	name := segment[1:] // Remove the ":"
+	optional := strings.HasSuffix(name, "?")
+	name = strings.TrimSuffix(name, "?")
 
-	for _, route := range r.routes {
-		if route.Method != method {
-			continue
+	var constraint string
+	var match func(string) bool
+	if open := strings.IndexByte(name, '<'); open >= 0 {
+		// The pattern is split on "/" first, so a constraint containing
+		// one arrives here cut short
+		if !strings.HasSuffix(name, ">") {
+			panic(fmt.Sprintf("smallapi: unterminated constraint in %q; constraints cannot contain \"/\"", pattern))
 		}
+		constraint = name[open+1 : len(name)-1]
+		name = name[:open]
+		match = constraintMatcher(constraint, pattern)
+	}
+
+	return Segment{
+		IsParam:    true,
+		Optional:   optional,
+		Constraint: constraint,
+		Name:       name,
+		match:      match,
+	}
+}
+
+// Match finds a matching route for the given method and path, ignoring
+// routes bound to a host.
+// Static segments take precedence over parameters, and parameters over
+// catch-alls, regardless of the order in which the routes were registered.
+func (r *Router) Match(method, path string) (HandlerFunc, map[string]string) {
+	route, params := r.Lookup("", method, path)
+	if route == nil {
+		return nil, nil
+	}
+	return route.Handler, params
+}
+
+// Lookup is like Match but takes the request host into account and returns
+// the matched route itself. Routes bound to a matching host win over routes
+// for any host; host parameters are returned along with path parameters.
+func (r *Router) Lookup(host, method, path string) (*Route, map[string]string) {
+	path = strings.Trim(path, "/")
+
+	if len(r.hosts) > 0 && host != "" {
+		host = normalizeHost(host)
+		for _, h := range r.hosts {
+			hostValues, ok := h.pattern.match(host)
+			if !ok {
+				continue
+			}
 
-		params, matches := r.matchPattern(route.Pattern, pathSegments)
-		if matches {
-			return route.Handler, params
+			route, params := lookupTree(h.trees[method], path)
+			if route == nil {
+				continue
+			}
+
+			if len(hostValues) > 0 && params == nil {
+				params = make(map[string]string, len(hostValues))
+			}
+			for i, name := range h.pattern.names {
+				// Path parameters win over host parameters of the same name
+				if _, exists := params[name]; !exists {
+					params[name] = hostValues[i]
+				}
+			}
+			return route, params
 		}
 	}
 
-	return nil, nil
+	return lookupTree(r.trees[method], path)
 }
 
-// matchPattern checks if a path matches a route pattern and extracts parameters
-func (r *Router) matchPattern(pattern *RoutePattern, pathSegments []string) (map[string]string, bool) {
-	if len(pattern.Segments) != len(pathSegments) {
-		return nil, false
+// lookupTree matches an already trimmed path against a method's tree
+func lookupTree(root *node, path string) (*Route, map[string]string) {
+	if root == nil {
+		return nil, nil
+	}
+
+	leaf, values := root.match(path, nil)
+	if leaf == nil {
+		return nil, nil
 	}
 
-	params := make(map[string]string)
+	// Static hits return without allocating a parameter map
+	if len(values) == 0 {
+		return leaf.route, nil
+	}
+
+	params := make(map[string]string, len(leaf.names))
+	for i, name := range leaf.names {
+		params[name] = values[i]
+	}
+
+	return leaf.route, params
+}
+
+// Allowed returns the methods that have a route matching host and path,
+// sorted. HEAD is implied by GET and OPTIONS by any other method.
+func (r *Router) Allowed(host, path string) []string {
+	path = strings.Trim(path, "/")
 
-	for i, segment := range pattern.Segments {
-		if segment.IsParam {
-			// Parameter segment - capture the value
-			params[segment.Name] = pathSegments[i]
-		} else {
-			// Static segment - must match exactly
-			if segment.Value != pathSegments[i] {
-				return nil, false
+	var methods []string
+	collect := func(trees map[string]*node) {
+		for method, root := range trees {
+			if leaf, _ := root.match(path, nil); leaf != nil && !containsString(methods, method) {
+				methods = append(methods, method)
 			}
 		}
 	}
 
-	return params, true
+	if host != "" {
+		host = normalizeHost(host)
+		for _, h := range r.hosts {
+			if _, ok := h.pattern.match(host); ok {
+				collect(h.trees)
+			}
+		}
+	}
+	collect(r.trees)
+
+	if len(methods) == 0 {
+		return nil
+	}
+
+	if containsString(methods, "GET") && !containsString(methods, "HEAD") {
+		methods = append(methods, "HEAD")
+	}
+	if !containsString(methods, "OPTIONS") {
+		methods = append(methods, "OPTIONS")
+	}
+	sort.Strings(methods)
+
+	return methods
 }
 
 // Routes returns all registered routes (useful for debugging)
 func (r *Router) Routes() []Route {
-	return r.routes
+	routes := make([]Route, len(r.routes))
+	for i, route := range r.routes {
+		routes[i] = *route
+	}
+	return routes
 }
 
-// PrintRoutes prints all registered routes (useful for debugging)
+// PrintRoutes prints a table of all registered routes (useful for debugging)
 func (r *Router) PrintRoutes() {
+	r.WriteRoutes(os.Stdout, nil)
+}
+
+// WriteRoutes writes a table of the registered routes with their method,
+// host, pattern, name, handler and middleware chain. global is the
+// application middleware that runs before every route.
+func (r *Router) WriteRoutes(w io.Writer, global []MiddlewareFunc) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tHOST\tPATH\tNAME\tHANDLER\tMIDDLEWARE")
+
 	for _, route := range r.routes {
-		println(route.Method, route.Path)
+		path := route.Path
+		for _, conflict := range r.conflicts {
+			if conflict.Route == route && !conflict.Partial {
+				path += " (unreachable)"
+			}
+		}
+
+		var chain []string
+		for _, middleware := range global {
+			chain = append(chain, funcName(middleware))
+		}
+		for _, middleware := range route.Middleware() {
+			chain = append(chain, funcName(middleware))
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			route.Method,
+			orDash(route.Host),
+			path,
+			orDash(route.Name),
+			funcName(route.Handler),
+			orDash(strings.Join(chain, " -> ")),
+		)
+	}
+
+	return tw.Flush()
+}
+
+// funcName returns the name of a function without its package path,
+// e.g. "smallapi.Logger.func1"
+func funcName(fn interface{}) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "?"
+	}
+	name := f.Name()
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// orDash returns s, or "-" when s is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
 	}
+	return s
 }
 
 // ServeDoc returns the API documentation as JSON
@@ -149,3 +529,285 @@ func (r *Router) ServeDoc() HandlerFunc {
 		c.JSON(string(doc))
 	}
 }
+
+// node is a node of the radix tree. Static children share common
+// prefixes; parameter children match a single path segment and the
+// wildcard child matches whatever is left of the path.
+type node struct {
+	prefix   string
+	indices  string  // first byte of each static child, in order
+	static   []*node // static children
+	params   []*node // ":name" children, constrained ones first
+	wildcard *node   // "*name" child
+	slash    bool    // wildcard only: the remainder must start with "/"
+	route    *Route  // route terminating at this node
+	names    []string
+
+	constraint string            // param only: constraint source
+	accept     func(string) bool // param only: nil accepts any value
+}
+
+// insert adds one variant of a route's pattern below n and returns the
+// route that already handles that variant, if any
+func (n *node) insert(segments []Segment, route *Route) *Route {
+	current := n
+	var static strings.Builder
+	var names []string
+
+	for i, segment := range segments {
+		if segment.IsWildcard {
+			// The wildcard hangs off the node before the separating slash
+			// so that it also matches an empty remainder
+			current = current.insertStatic(static.String())
+			if current.wildcard == nil {
+				current.wildcard = &node{slash: i > 0}
+			}
+			return current.wildcard.setRoute(route, append(names, segment.Name))
+		}
+
+		if i > 0 {
+			static.WriteByte('/')
+		}
+
+		if !segment.IsParam {
+			static.WriteString(segment.Value)
+			continue
+		}
+
+		// Flush the pending static part before descending into the parameter
+		current = current.insertStatic(static.String())
+		static.Reset()
+
+		current = current.paramChild(segment)
+		names = append(names, segment.Name)
+	}
+
+	return current.insertStatic(static.String()).setRoute(route, names)
+}
+
+// paramChild returns the parameter child for the segment's constraint,
+// creating it if needed. Constrained children are kept ahead of the
+// unconstrained one so that they are tried first.
+func (n *node) paramChild(segment Segment) *node {
+	for _, child := range n.params {
+		if child.constraint == segment.Constraint {
+			return child
+		}
+	}
+
+	child := &node{constraint: segment.Constraint, accept: segment.match}
+	if segment.Constraint == "" || len(n.params) == 0 || n.params[len(n.params)-1].constraint != "" {
+		n.params = append(n.params, child)
+	} else {
+		last := n.params[len(n.params)-1]
+		n.params = append(n.params[:len(n.params)-1], child, last)
+	}
+	return child
+}
+
+// paramProbes are sample values tried against two parameter constraints
+// to find one both accept
+var paramProbes = []string{
+	"0", "1", "42", "a", "abc", "ABC", "a1", "-1", "1.5", "1e3",
+	"true", "false", "t", "f", "a-b", "a_b", "a.b", "inf", "nan",
+	"2024-01-01", "123e4567-e89b-12d3-a456-426614174000",
+}
+
+// overlap looks for a path of an inserted pattern variant that an earlier
+// parameter with a different constraint takes first, as it is tried in
+// registration order. It returns the route that gets the path, and the
+// path.
+func (n *node) overlap(segments []Segment) (*Route, string) {
+	current := n
+	var static strings.Builder
+
+	for i, segment := range segments {
+		if segment.IsWildcard {
+			break
+		}
+		if i > 0 {
+			static.WriteByte('/')
+		}
+		if !segment.IsParam {
+			static.WriteString(segment.Value)
+			continue
+		}
+
+		parent := current.insertStatic(static.String())
+		static.Reset()
+		current = parent.paramChild(segment)
+		if segment.Constraint == "" {
+			continue
+		}
+
+		prefix, ok := probePath(segments[:i])
+		rest, restOK := probePath(segments[i+1:])
+		if !ok || !restOK {
+			return nil, ""
+		}
+		for _, sibling := range parent.params {
+			if sibling == current {
+				break
+			}
+			for _, value := range paramProbes {
+				if !sibling.accept(value) || !current.accept(value) {
+					continue
+				}
+				if leaf, _ := sibling.match(rest, nil); leaf != nil {
+					return leaf.route, prefix + "/" + value + rest
+				}
+			}
+		}
+	}
+	return nil, ""
+}
+
+// probePath builds a path matching segments, each starting with "/", with
+// a probe value for each parameter; ok is false if a constraint accepts
+// none of them
+func probePath(segments []Segment) (path string, ok bool) {
+	var b strings.Builder
+	for _, segment := range segments {
+		b.WriteByte('/')
+		switch {
+		case segment.IsParam:
+			value := ""
+			for _, probe := range paramProbes {
+				if segment.match == nil || segment.match(probe) {
+					value = probe
+					break
+				}
+			}
+			if value == "" {
+				return "", false
+			}
+			b.WriteString(value)
+		case segment.IsWildcard:
+			b.WriteString("x")
+		default:
+			b.WriteString(segment.Value)
+		}
+	}
+	return b.String(), true
+}
+
+// setRoute makes n terminate route. The first registration of a pattern
+// wins; the route already terminating n is returned if there is one.
+func (n *node) setRoute(route *Route, names []string) *Route {
+	if n.route != nil {
+		return n.route
+	}
+	n.route = route
+	n.names = names
+	return nil
+}
+
+// insertStatic walks or creates the static path s below n, splitting
+// existing edges as needed, and returns the node at the end of s
+func (n *node) insertStatic(s string) *node {
+	for len(s) > 0 {
+		child := n.staticChild(s[0])
+		if child == nil {
+			child = &node{prefix: s}
+			n.indices += s[:1]
+			n.static = append(n.static, child)
+			return child
+		}
+
+		common := commonPrefix(s, child.prefix)
+		if common < len(child.prefix) {
+			// Split the edge: child keeps the shared prefix and the
+			// remainder moves down into a new node
+			rest := *child
+			rest.prefix = child.prefix[common:]
+			*child = node{
+				prefix:  child.prefix[:common],
+				indices: rest.prefix[:1],
+				static:  []*node{&rest},
+			}
+		}
+
+		n = child
+		s = s[common:]
+	}
+	return n
+}
+
+// staticChild returns the static child starting with the given byte
+func (n *node) staticChild(label byte) *node {
+	for i := 0; i < len(n.indices); i++ {
+		if n.indices[i] == label {
+			return n.static[i]
+		}
+	}
+	return nil
+}
+
+// match looks up path below n, trying static children, then the
+// parameter children, then the wildcard, and backtracking when a branch
+// dead-ends
+func (n *node) match(path string, values []string) (*node, []string) {
+	if path == "" && n.route != nil {
+		return n, values
+	}
+
+	if path != "" {
+		if child := n.staticChild(path[0]); child != nil && strings.HasPrefix(path, child.prefix) {
+			if leaf, vals := child.match(path[len(child.prefix):], values); leaf != nil {
+				return leaf, vals
+			}
+		}
+	}
+
+	if len(n.params) > 0 && path != "" {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			value := path[:end]
+			for _, child := range n.params {
+				if child.accept != nil && !child.accept(value) {
+					continue
+				}
+				if leaf, vals := child.match(path[end:], append(values, value)); leaf != nil {
+					return leaf, vals
+				}
+			}
+		}
+	}
+
+	if n.wildcard != nil {
+		if !n.wildcard.slash {
+			return n.wildcard, append(values, path)
+		}
+		if path == "" || path[0] == '/' {
+			return n.wildcard, append(values, strings.TrimPrefix(path, "/"))
+		}
+	}
+
+	return nil, nil
+}
+
+// commonPrefix returns the length of the longest common prefix of a and b
+func commonPrefix(a, b string) int {
+	max := len(a)
+	if len(b) < max {
+		max = len(b)
+	}
+	i := 0
+	for i < max && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
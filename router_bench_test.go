@@ -0,0 +1,201 @@
+package smallapi
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// benchResources are combined into a realistic 400-route API surface
+var benchResources = []string{
+	"users", "posts", "comments", "orders", "invoices", "products", "carts", "payments",
+	"shipments", "reviews", "tags", "categories", "teams", "projects", "tasks", "files",
+	"folders", "events", "tickets", "reports", "alerts", "webhooks", "tokens", "roles",
+	"groups", "plans", "coupons", "refunds", "messages", "threads", "notes", "boards",
+	"sprints", "releases", "builds", "deploys", "secrets", "domains", "regions", "zones",
+}
+
+// benchRouter returns a router with ten routes per resource
+func benchRouter() *Router {
+	router := NewRouter()
+	for _, pattern := range benchPatterns() {
+		router.Add("GET", pattern, func(c *Context) {})
+	}
+	return router
+}
+
+// benchPatterns returns ten route patterns per resource
+func benchPatterns() []string {
+	var patterns []string
+	for _, res := range benchResources {
+		patterns = append(patterns,
+			"/api/v1/"+res,
+			"/api/v1/"+res+"/search",
+			"/api/v1/"+res+"/export",
+			"/api/v1/"+res+"/stats",
+			"/api/v1/"+res+"/:id",
+			"/api/v1/"+res+"/:id/history",
+			"/api/v1/"+res+"/:id/owners",
+			"/api/v1/"+res+"/:id/owners/:ownerId",
+			"/api/v1/"+res+"/:id/audit",
+			"/api/v1/"+res+"/:id/audit/:entryId",
+		)
+	}
+	return patterns
+}
+
+// linearRouter is the route scan the radix tree replaced, kept to compare
+// against: Match tries every route of the method in registration order
+type linearRouter struct {
+	routes []linearRoute
+}
+
+// linearRoute is a route as the linear scan stored it
+type linearRoute struct {
+	method  string
+	handler HandlerFunc
+	pattern []Segment
+}
+
+// Add compiles path into static and parameter segments
+func (r *linearRouter) Add(method, path string, handler HandlerFunc) {
+	var pattern []Segment
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if strings.HasPrefix(segment, ":") {
+			pattern = append(pattern, Segment{IsParam: true, Name: segment[1:]})
+		} else {
+			pattern = append(pattern, Segment{Value: segment})
+		}
+	}
+	r.routes = append(r.routes, linearRoute{method: method, handler: handler, pattern: pattern})
+}
+
+// Match finds a matching route for the given method and path
+func (r *linearRouter) Match(method, path string) (HandlerFunc, map[string]string) {
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for _, route := range r.routes {
+		if route.method != method {
+			continue
+		}
+
+		params, matches := r.matchPattern(route.pattern, pathSegments)
+		if matches {
+			return route.handler, params
+		}
+	}
+
+	return nil, nil
+}
+
+// matchPattern checks if a path matches a route pattern and extracts parameters
+func (r *linearRouter) matchPattern(pattern []Segment, pathSegments []string) (map[string]string, bool) {
+	if len(pattern) != len(pathSegments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+
+	for i, segment := range pattern {
+		if segment.IsParam {
+			params[segment.Name] = pathSegments[i]
+		} else if segment.Value != pathSegments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// benchLinearRouter returns the linear scan over the routes of benchRouter
+func benchLinearRouter() *linearRouter {
+	router := &linearRouter{}
+	for _, pattern := range benchPatterns() {
+		router.Add("GET", pattern, func(c *Context) {})
+	}
+	return router
+}
+
+// benchPaths are looked up by BenchmarkLookup, from the best case of the
+// linear scan to its worst
+func benchPaths() []struct{ name, path string } {
+	last := benchResources[len(benchResources)-1]
+	return []struct{ name, path string }{
+		{"static-first", "/api/v1/users"},
+		{"static-last", "/api/v1/" + last + "/stats"},
+		{"param-last", "/api/v1/" + last + "/42/audit/7"},
+		{"not-found", "/api/v1/unknown/42/x"},
+	}
+}
+
+// BenchmarkLookup compares the radix tree with the linear scan it replaced
+// on the same 400 routes and paths
+func BenchmarkLookup(b *testing.B) {
+	radix, linear := benchRouter(), benchLinearRouter()
+
+	for _, tc := range benchPaths() {
+		b.Run("radix/"+tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				radix.Lookup("", "GET", tc.path)
+			}
+		})
+		b.Run("linear/"+tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				linear.Match("GET", tc.path)
+			}
+		})
+	}
+}
+
+// TestLinearRouterAgrees checks that both routers of BenchmarkLookup find
+// the same routes, so the benchmark compares equal work
+func TestLinearRouterAgrees(t *testing.T) {
+	radix, linear := benchRouter(), benchLinearRouter()
+
+	for _, tc := range benchPaths() {
+		route, params := radix.Lookup("", "GET", tc.path)
+		handler, linearParams := linear.Match("GET", tc.path)
+		if (route == nil) != (handler == nil) {
+			t.Errorf("%s: radix found %v, linear found %v", tc.path, route != nil, handler != nil)
+			continue
+		}
+		if len(params) != len(linearParams) {
+			t.Errorf("%s: params %v, linear %v", tc.path, params, linearParams)
+			continue
+		}
+		for key, value := range linearParams {
+			if params[key] != value {
+				t.Errorf("%s: params %v, linear %v", tc.path, params, linearParams)
+			}
+		}
+	}
+}
+
+func BenchmarkLookupConstrained(b *testing.B) {
+	router := NewRouter()
+	handler := func(c *Context) {}
+	router.Add("GET", "/files/:id<int>", handler)
+	router.Add("GET", "/files/:slug<[a-z-]+>", handler)
+	router.Add("GET", "/files/*path", handler)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		router.Lookup("", "GET", "/files/annual-report")
+	}
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	app := New()
+	for _, pattern := range benchPatterns() {
+		app.Get(pattern, func(c *Context) { c.String("ok") })
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/zones/42/audit/7", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		app.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
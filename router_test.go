@@ -0,0 +1,281 @@
+package smallapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+// lookupCase is a request path and the route pattern and parameters it
+// should match; an empty route means no match
+type lookupCase struct {
+	path   string
+	route  string
+	params map[string]string
+}
+
+// testRouter registers GET routes for patterns, in order
+func testRouter(t *testing.T, patterns ...string) *Router {
+	t.Helper()
+	router := NewRouter()
+	for _, pattern := range patterns {
+		router.Add("GET", pattern, func(c *Context) {})
+	}
+	return router
+}
+
+// checkLookups runs lookup cases against router
+func checkLookups(t *testing.T, router *Router, cases []lookupCase) {
+	t.Helper()
+	for _, tc := range cases {
+		route, params := router.Lookup("", "GET", tc.path)
+		got := ""
+		if route != nil {
+			got = route.Path
+		}
+		if got != tc.route {
+			t.Errorf("%s: matched %q, want %q", tc.path, got, tc.route)
+			continue
+		}
+		if len(params) == 0 && len(tc.params) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(params, tc.params) {
+			t.Errorf("%s: params %v, want %v", tc.path, params, tc.params)
+		}
+	}
+}
+
+func TestRouterParams(t *testing.T) {
+	router := testRouter(t,
+		"/",
+		"/users",
+		"/users/:id",
+		"/users/:id/posts/:postId",
+		"/orgs/:org/repos/:repo/issues",
+	)
+	checkLookups(t, router, []lookupCase{
+		{"/", "/", nil},
+		{"/users", "/users", nil},
+		{"/users/", "/users", nil},
+		{"/users/42", "/users/:id", map[string]string{"id": "42"}},
+		{"/users/42/posts/7", "/users/:id/posts/:postId", map[string]string{"id": "42", "postId": "7"}},
+		{"/orgs/go/repos/net/issues", "/orgs/:org/repos/:repo/issues", map[string]string{"org": "go", "repo": "net"}},
+		{"/users/42/posts", "", nil},
+		{"/users/42/posts/7/x", "", nil},
+		{"/user", "", nil},
+		{"/usersx", "", nil},
+		{"/nope", "", nil},
+	})
+}
+
+func TestRouterPrecedence(t *testing.T) {
+	// Registration order must not matter: static beats parameter
+	for _, patterns := range [][]string{
+		{"/users/:id", "/users/me", "/users/mean"},
+		{"/users/mean", "/users/me", "/users/:id"},
+	} {
+		router := testRouter(t, patterns...)
+		checkLookups(t, router, []lookupCase{
+			{"/users/me", "/users/me", nil},
+			{"/users/mean", "/users/mean", nil},
+			{"/users/m", "/users/:id", map[string]string{"id": "m"}},
+			{"/users/meanest", "/users/:id", map[string]string{"id": "meanest"}},
+		})
+	}
+}
+
+func TestRouterBacktracking(t *testing.T) {
+	// "/a/b/d" enters the static "/a/b" branch, which dead-ends, and must
+	// fall back to the parameter branch
+	router := testRouter(t,
+		"/a/b/c",
+		"/a/:x/d",
+		"/static/:name/edit",
+		"/static/files/list",
+	)
+	checkLookups(t, router, []lookupCase{
+		{"/a/b/c", "/a/b/c", nil},
+		{"/a/b/d", "/a/:x/d", map[string]string{"x": "b"}},
+		{"/a/z/d", "/a/:x/d", map[string]string{"x": "z"}},
+		{"/static/files/edit", "/static/:name/edit", map[string]string{"name": "files"}},
+		{"/static/files/list", "/static/files/list", nil},
+		{"/a/b", "", nil},
+	})
+}
+
+func TestRouterMethods(t *testing.T) {
+	router := NewRouter()
+	router.Add("GET", "/items/:id", func(c *Context) {})
+	router.Add("DELETE", "/items/:id", func(c *Context) {})
+
+	if route, _ := router.Lookup("", "DELETE", "/items/1"); route == nil || route.Method != "DELETE" {
+		t.Errorf("DELETE /items/1 matched %v", route)
+	}
+	if route, _ := router.Lookup("", "POST", "/items/1"); route != nil {
+		t.Errorf("POST /items/1 matched %s %s", route.Method, route.Path)
+	}
+}
+
+func TestRouterConstraints(t *testing.T) {
+	router := testRouter(t,
+		"/users/me",
+		"/users/:id<int>",
+		"/users/:name",
+		"/tags/:slug<[a-z-]+>",
+		"/items/:id<uuid>",
+		"/flags/:on<bool>",
+		"/prices/:amount<float>",
+	)
+	checkLookups(t, router, []lookupCase{
+		{"/users/me", "/users/me", nil},
+		{"/users/42", "/users/:id<int>", map[string]string{"id": "42"}},
+		{"/users/-7", "/users/:id<int>", map[string]string{"id": "-7"}},
+		{"/users/bob", "/users/:name", map[string]string{"name": "bob"}},
+		{"/tags/go-lang", "/tags/:slug<[a-z-]+>", map[string]string{"slug": "go-lang"}},
+		{"/tags/Go", "", nil},
+		{"/tags/go1", "", nil}, // the expression must match the whole segment
+		{"/items/123e4567-e89b-12d3-a456-426614174000", "/items/:id<uuid>", map[string]string{"id": "123e4567-e89b-12d3-a456-426614174000"}},
+		{"/items/123", "", nil},
+		{"/flags/true", "/flags/:on<bool>", map[string]string{"on": "true"}},
+		{"/flags/yes", "", nil},
+		{"/prices/9.99", "/prices/:amount<float>", map[string]string{"amount": "9.99"}},
+	})
+}
+
+func TestRouterInvalidPatterns(t *testing.T) {
+	for _, pattern := range []string{
+		"/files/:path<[a-z/]+>", // "/" splits the constraint
+		"/files/:id<int",
+		"/tags/:slug<[a-z>",
+		"/files/*path/edit", // catch-all must be last
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: registered without a panic", pattern)
+				}
+			}()
+			testRouter(t, pattern)
+		}()
+	}
+}
+
+func TestRouterConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string // conflicting route, then the variant
+		partial  bool
+	}{
+		{"duplicate", []string{"/users/:id", "/users/:id"}, []string{"/users/:id", "/users/{id}"}, false},
+		{"renamed param", []string{"/users/:id", "/users/:name"}, []string{"/users/:name", "/users/{name}"}, false},
+		{"same constraint", []string{"/users/:id<int>", "/users/:n<int>"}, []string{"/users/:n<int>", "/users/{n}"}, false},
+		{"optional variant", []string{"/posts", "/posts/:id?"}, []string{"/posts/:id?", "/posts"}, true},
+		{"all optional variants", []string{"/posts/:id", "/posts", "/posts/:slug?"}, []string{"/posts/:slug?", "/posts/{slug}"}, false},
+		{"catch-all", []string{"/files/*path", "/files/*rest"}, []string{"/files/*rest", "/files/{rest}"}, false},
+		{"param and catch-all overlap", []string{"/files/:name", "/files/*path"}, nil, false},
+		{"static and param overlap", []string{"/users/me", "/users/:id"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts := testRouter(t, tt.patterns...).Conflicts()
+			if tt.want == nil {
+				if len(conflicts) != 0 {
+					t.Fatalf("got conflict %v, want none", conflicts[0])
+				}
+				return
+			}
+			if len(conflicts) != 1 {
+				t.Fatalf("got %d conflicts, want 1", len(conflicts))
+			}
+			conflict := conflicts[0]
+			if conflict.Route.Path != tt.want[0] || conflict.Variant != tt.want[1] || conflict.Partial != tt.partial {
+				t.Errorf("got %s %s partial=%v, want %s %s partial=%v",
+					conflict.Route.Path, conflict.Variant, conflict.Partial, tt.want[0], tt.want[1], tt.partial)
+			}
+		})
+	}
+}
+
+func TestRouterConflictsByMethodAndHost(t *testing.T) {
+	router := NewRouter()
+	router.Add("GET", "/users/:id", func(c *Context) {})
+	router.Add("POST", "/users/:id", func(c *Context) {})
+	router.AddHost("api.example.com", "GET", "/users/:id", func(c *Context) {})
+	if conflicts := router.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("got conflict %v, want none", conflicts[0])
+	}
+}
+
+func TestRouterStrict(t *testing.T) {
+	router := NewRouter()
+	router.SetStrict(true)
+	router.Add("GET", "/users/:id", func(c *Context) {})
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate route registered without a panic")
+		}
+	}()
+	router.Add("GET", "/users/:name", func(c *Context) {})
+}
+
+func TestRouterConstraintOverlaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		existing string // the route reported as taking the paths, "" for none
+		path     string
+	}{
+		{"subset regex after type", []string{"/items/:id<int>", "/items/:n<[0-9]+>"}, "/items/:id<int>", "/items/0"},
+		{"type after regex", []string{"/items/:n<[0-9]+>", "/items/:id<int>"}, "/items/:n<[0-9]+>", "/items/0"},
+		{"partial overlap", []string{"/items/:id<int>", "/items/:slug<alphanum>"}, "/items/:id<int>", "/items/0"},
+		{"overlap below", []string{"/a/:x<alpha>/b/:id<int>", "/a/:y<alpha>/b/:n<uint>"}, "/a/:x<alpha>/b/:id<int>", "/a/a/b/0"},
+		{"with rest", []string{"/v/:id<int>/edit", "/v/:n<[0-9]+>/edit"}, "/v/:id<int>/edit", "/v/0/edit"},
+		{"with catch-all", []string{"/f/:id<int>/*path", "/f/:slug<[a-z0-9]+>/*rest"}, "/f/:id<int>/*path", "/f/0/x"},
+		{"disjoint types", []string{"/items/:id<int>", "/items/:slug<alpha>", "/items/:key<uuid>"}, "", ""},
+		{"disjoint regexes", []string{"/tags/:slug<[a-z-]+>", "/tags/:id<[0-9]+>"}, "", ""},
+		{"different rest", []string{"/v/:id<int>/edit", "/v/:n<[0-9]+>/view"}, "", ""},
+		{"plain parameter", []string{"/users/:id<int>", "/users/:name"}, "", ""},
+		{"constraint after plain parameter", []string{"/users/:name", "/users/:id<int>"}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts := testRouter(t, tt.patterns...).Conflicts()
+			if tt.existing == "" {
+				if len(conflicts) != 0 {
+					t.Fatalf("got conflict %v, want none", conflicts[0])
+				}
+				return
+			}
+			if len(conflicts) != 1 {
+				t.Fatalf("got %d conflicts, want 1", len(conflicts))
+			}
+			conflict := conflicts[0]
+			if conflict.Existing.Path != tt.existing || conflict.Path != tt.path || !conflict.Partial {
+				t.Errorf("got %s taking %s, partial=%v, want %s taking %s",
+					conflict.Existing.Path, conflict.Path, conflict.Partial, tt.existing, tt.path)
+			}
+
+			// The reported path really goes to the earlier route
+			router := testRouter(t, tt.patterns...)
+			if route, _ := router.Lookup("", "GET", tt.path); route == nil || route.Path != tt.existing {
+				t.Errorf("%s matched %v, want %s", tt.path, route, tt.existing)
+			}
+		})
+	}
+}
+
+func TestRouterStrictOverlap(t *testing.T) {
+	router := NewRouter()
+	router.SetStrict(true)
+	router.Add("GET", "/items/:id<int>", func(c *Context) {})
+	router.Add("GET", "/items/:slug<alpha>", func(c *Context) {})
+	defer func() {
+		if recover() == nil {
+			t.Error("overlapping constraint registered without a panic")
+		}
+	}()
+	router.Add("GET", "/items/:n<[0-9]+>", func(c *Context) {})
+}
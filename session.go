@@ -3,67 +3,381 @@ package smallapi
 import (
         "crypto/rand"
         "encoding/base64"
+        "errors"
+        "fmt"
+        "log"
         "net/http"
+        "strings"
         "sync"
+        "sync/atomic"
         "time"
 )
 
-// Session represents a user session
+// Session represents a user session. Its data is loaded from the
+// application's SessionStore and written back before the response headers
+// are sent, only if it was modified.
 type Session struct {
-        id     string
-        data   map[string]interface{}
-        mutex  sync.RWMutex
-        maxAge time.Duration
+        id       string
+        data     map[string]interface{}
+        mutex    sync.RWMutex
+        created  time.Time
+        accessed time.Time           // by the previous request
+        isNew    bool                // not in the store yet
+        dirty    bool                // modified since it was loaded
+        w        http.ResponseWriter // receives the session cookie
+        manager  *SessionManager
+
+        // expireCookie removes the cookie of an ended session the client
+        // still holds, unless data is put in the new session
+        expireCookie bool
+}
+
+// SessionUserKey is the session key holding the ID of the logged-in user.
+// SessionManager.DestroyAllFor finds the sessions of a user by it.
+const SessionUserKey = "user_id"
+
+// SessionConfig configures how long sessions last and their cookie
+type SessionConfig struct {
+        // MaxAge ends a session that long after it was created, however
+        // active it is. Defaults to 24 hours.
+        MaxAge time.Duration
+
+        // IdleTimeout ends a session not used for that long. Zero disables
+        // it.
+        IdleTimeout time.Duration
+
+        // CleanupInterval is how often expired sessions are removed from the
+        // store. Defaults to an hour.
+        CleanupInterval time.Duration
+
+        // CookieName names the session cookie. Defaults to "session_id".
+        CookieName string
+
+        // Domain and Path scope the cookie; Path defaults to "/"
+        Domain string
+        Path   string
+
+        // Secure sends the cookie over HTTPS only. Enable it in production.
+        Secure bool
+
+        // SameSite defaults to http.SameSiteLaxMode. http.SameSiteNoneMode
+        // requires Secure.
+        SameSite http.SameSite
+
+        // Partitioned keeps the cookie of an embedded site separate for each
+        // top-level site (CHIPS). It requires Secure.
+        Partitioned bool
+
+        // HostPrefix prefixes the cookie name with "__Host-", so browsers only
+        // accept it from this exact host over HTTPS. It implies Secure and
+        // cannot be combined with Domain or a Path other than "/".
+        HostPrefix bool
+}
+
+// SessionStats counts sessions, for monitoring
+type SessionStats struct {
+        Active  int    `json:"active"`  // sessions in the store, -1 for cookie sessions
+        Created uint64 `json:"created"` // sessions stored since startup
+        Expired uint64 `json:"expired"` // sessions ended by MaxAge or IdleTimeout
 }
 
 // SessionManager manages user sessions
 type SessionManager struct {
-        sessions map[string]*Session
-        mutex    sync.RWMutex
-        maxAge   time.Duration
+        store   SessionStore
+        cookies *cookieCodec // keeps sessions in the cookie instead, see CookieSessions
+        config  SessionConfig
+
+        cleanupOnce sync.Once
+        closeOnce   sync.Once
+        stop        chan struct{}
+
+        created atomic.Uint64
+        expired atomic.Uint64
 }
 
-// NewSessionManager creates a new session manager
+// NewSessionManager creates a session manager keeping sessions in memory
 func NewSessionManager() *SessionManager {
+        return NewSessionManagerWithStore(NewMemoryStore())
+}
+
+// NewSessionManagerWithStore creates a session manager keeping sessions in
+// store. Expired sessions are removed from the store in the background
+// once sessions are used, until Close is called.
+func NewSessionManagerWithStore(store SessionStore) *SessionManager {
         sm := &SessionManager{
-                sessions: make(map[string]*Session),
-                maxAge:   24 * time.Hour, // 24 hours default
+                store: store,
+                stop:  make(chan struct{}),
+        }
+        sm.Configure(SessionConfig{})
+        return sm
+}
+
+// Configure sets how long sessions last and their cookie. Zero fields take
+// their defaults.
+func (sm *SessionManager) Configure(config SessionConfig) error {
+        if config.MaxAge <= 0 {
+                config.MaxAge = 24 * time.Hour
+        }
+        if config.CleanupInterval <= 0 {
+                config.CleanupInterval = time.Hour
+        }
+        if config.CookieName == "" {
+                config.CookieName = "session_id"
+        }
+        if config.Path == "" {
+                config.Path = "/"
+        }
+        if config.SameSite == 0 {
+                config.SameSite = http.SameSiteLaxMode
         }
         
-        // Start cleanup routine
-        go sm.cleanup()
+        if config.HostPrefix {
+                if config.Domain != "" || config.Path != "/" {
+                        return errors.New("smallapi: __Host- session cookies cannot have a Domain or a Path other than /")
+                }
+                config.Secure = true
+                if !strings.HasPrefix(config.CookieName, "__Host-") {
+                        config.CookieName = "__Host-" + config.CookieName
+                }
+        }
+        if !config.Secure && (config.SameSite == http.SameSiteNoneMode || config.Partitioned) {
+                return errors.New("smallapi: SameSite=None and Partitioned session cookies must be Secure")
+        }
         
-        return sm
+        sm.config = config
+        return nil
+}
+
+// SessionStore sets where sessions are kept, in memory by default. Use a
+// FileStore or KVStore to keep sessions across restarts, or your own
+// store to share them between servers.
+//
+//	store, err := smallapi.NewFileStore("./sessions")
+//	if err != nil {
+//	        log.Fatal(err)
+//	}
+//	app.SessionStore(store)
+func (a *App) SessionStore(store SessionStore) *App {
+        a.sessions.store = store
+        return a
+}
+
+// ConfigureSessions sets how long sessions last and their cookie. Call it
+// when setting up the application; it panics on an invalid configuration.
+//
+//	app.ConfigureSessions(smallapi.SessionConfig{
+//	        MaxAge:      12 * time.Hour,
+//	        IdleTimeout: 30 * time.Minute,
+//	        Secure:      true,
+//	        HostPrefix:  true,
+//	})
+func (a *App) ConfigureSessions(config SessionConfig) *App {
+        if err := a.sessions.Configure(config); err != nil {
+                panic(err)
+        }
+        return a
 }
 
-// GetSession gets or creates a session for a request
+// Sessions returns the session manager of the application
+func (a *App) Sessions() *SessionManager {
+        return a.sessions
+}
+
+// GetSession loads the session of a request, or starts one with a new ID.
+// A new session is only stored, and its cookie set, once data is put in
+// it. Sessions past their MaxAge or IdleTimeout are removed and replaced.
 func (sm *SessionManager) GetSession(r *http.Request, w http.ResponseWriter) *Session {
-        // Try to get session ID from cookie
-        cookie, err := r.Cookie("session_id")
-        var sessionID string
+        if sm.cookies != nil {
+                return sm.loadCookieSession(r, w)
+        }
+        sm.cleanupOnce.Do(func() { go sm.cleanup() })
         
-        if err != nil || cookie.Value == "" {
-                // Create new session
-                sessionID = sm.generateSessionID()
-                sm.setSessionCookie(w, sessionID)
-        } else {
-                sessionID = cookie.Value
+        if cookie, err := r.Cookie(sm.config.CookieName); err == nil && cookie.Value != "" {
+                record, err := sm.store.Load(cookie.Value)
+                if err == nil && sm.timedOut(record.Created, record.Accessed) {
+                        sm.expired.Add(1)
+                        if err := sm.store.Delete(cookie.Value); err != nil {
+                                log.Printf("Error removing expired session: %v", err)
+                        }
+                        err = ErrSessionNotFound
+                }
+                if err == nil {
+                        return &Session{
+                                id:       cookie.Value,
+                                data:     record.Data,
+                                created:  record.Created,
+                                accessed: record.Accessed,
+                                w:        w,
+                                manager:  sm,
+                        }
+                }
+                if !errors.Is(err, ErrSessionNotFound) {
+                        log.Printf("Error loading session: %v", err)
+                        return sm.newSession(sm.generateSessionID(), w)
+                }
+                
+                // Unknown and expired IDs are replaced rather than adopted
+                session := sm.newSession(sm.generateSessionID(), w)
+                session.expireCookie = true
+                return session
         }
         
-        sm.mutex.Lock()
-        defer sm.mutex.Unlock()
+        return sm.newSession(sm.generateSessionID(), w)
+}
+
+// newSession starts an empty session
+func (sm *SessionManager) newSession(id string, w http.ResponseWriter) *Session {
+        now := time.Now()
+        return &Session{
+                id:       id,
+                data:     make(map[string]interface{}),
+                created:  now,
+                accessed: now,
+                isNew:    true,
+                w:        w,
+                manager:  sm,
+        }
+}
+
+// timedOut reports whether a session created and last used at the given
+// times has passed its MaxAge or IdleTimeout
+func (sm *SessionManager) timedOut(created, accessed time.Time) bool {
+        now := time.Now()
+        if now.Sub(created) > sm.config.MaxAge {
+                return true
+        }
+        return sm.config.IdleTimeout > 0 && now.Sub(accessed) > sm.config.IdleTimeout
+}
+
+// expiry returns when a session created at the given time and used now
+// ends: at its MaxAge, or earlier once idle for IdleTimeout
+func (sm *SessionManager) expiry(created, now time.Time) time.Time {
+        expiry := created.Add(sm.config.MaxAge)
+        if sm.config.IdleTimeout > 0 && now.Add(sm.config.IdleTimeout).Before(expiry) {
+                expiry = now.Add(sm.config.IdleTimeout)
+        }
+        return expiry
+}
+
+// Save writes a modified session to the store, setting the cookie of new
+// ones, and otherwise records the access and extends its expiry. New
+// sessions left empty are not kept. Cookie sessions are written to the
+// response cookie when modified. It is called before the response
+// headers are sent, and again after the handler in case the session
+// changed later.
+func (sm *SessionManager) Save(session *Session) error {
+        session.mutex.Lock()
+        defer session.mutex.Unlock()
+        
+        if session.isNew && !session.dirty {
+                if session.expireCookie {
+                        sm.setSessionCookie(session.w, "", -1)
+                        session.expireCookie = false
+                }
+                return nil
+        }
         
-        session, exists := sm.sessions[sessionID]
-        if !exists {
-                session = &Session{
-                        id:     sessionID,
-                        data:   make(map[string]interface{}),
-                        maxAge: sm.maxAge,
+        now := time.Now()
+        expiry := sm.expiry(session.created, now)
+        if sm.cookies != nil {
+                return sm.saveCookieSession(session, now, expiry)
+        }
+        if !session.isNew && !session.dirty {
+                err := sm.store.Touch(session.id, now, expiry)
+                if errors.Is(err, ErrSessionNotFound) {
+                        return nil // removed by another request meanwhile
                 }
-                sm.sessions[sessionID] = session
+                return err
+        }
+        
+        record := &SessionRecord{
+                Data:     session.data,
+                UserID:   sessionUser(session.data),
+                Created:  session.created,
+                Accessed: now,
+                Expiry:   expiry,
+        }
+        if err := sm.store.Save(session.id, record); err != nil {
+                return err
+        }
+        if session.isNew {
+                sm.created.Add(1)
+                sm.setSessionCookie(session.w, session.id, int(sm.config.MaxAge.Seconds()))
+        }
+        session.isNew, session.dirty, session.expireCookie = false, false, false
+        return nil
+}
+
+// sessionUser returns the user ID stored in session data under
+// SessionUserKey, or "" when there is none
+func sessionUser(data map[string]interface{}) string {
+        userID, ok := data[SessionUserKey]
+        if !ok || userID == nil {
+                return ""
+        }
+        return fmt.Sprint(userID)
+}
+
+// saveSession saves session, logging failures as there is no one to
+// return them to
+func (sm *SessionManager) saveSession(session *Session) {
+        if err := sm.Save(session); err != nil {
+                log.Printf("Error saving session: %v", err)
+        }
+}
+
+// saveLate saves a session modified after the response headers were
+// sent. A new session can no longer get its cookie, so it is dropped.
+func (sm *SessionManager) saveLate(session *Session) {
+        session.mutex.RLock()
+        isNew := session.isNew
+        session.mutex.RUnlock()
+        
+        if isNew {
+                log.Printf("Session started after the response headers were sent was not saved")
+                return
+        }
+        sm.saveSession(session)
+}
+
+// DestroyAllFor removes every session of a user, as found by the
+// SessionUserKey value, to log the user out everywhere. It returns the
+// number of sessions removed. Cookie sessions live on the clients and
+// cannot be destroyed this way.
+func (sm *SessionManager) DestroyAllFor(userID string) (int, error) {
+        if sm.cookies != nil {
+                return 0, errors.New("smallapi: cookie sessions cannot be destroyed on the server")
+        }
+        if userID == "" {
+                return 0, nil // not the sessions of everyone logged out
+        }
+        return sm.store.DeleteUser(userID)
+}
+
+// Stats returns the number of active sessions and counts of sessions
+// created and expired since the manager was created
+func (sm *SessionManager) Stats() (SessionStats, error) {
+        stats := SessionStats{
+                Active:  -1,
+                Created: sm.created.Load(),
+                Expired: sm.expired.Load(),
+        }
+        if sm.cookies != nil {
+                return stats, nil
         }
         
-        return session
+        active, err := sm.store.Count()
+        if err != nil {
+                return stats, err
+        }
+        stats.Active = active
+        return stats, nil
+}
+
+// Close stops removing expired sessions in the background. It does not
+// close the store.
+func (sm *SessionManager) Close() {
+        sm.closeOnce.Do(func() { close(sm.stop) })
 }
 
 // generateSessionID generates a unique session ID
@@ -73,30 +387,49 @@ func (sm *SessionManager) generateSessionID() string {
         return base64.URLEncoding.EncodeToString(bytes)
 }
 
-// setSessionCookie sets the session cookie
-func (sm *SessionManager) setSessionCookie(w http.ResponseWriter, sessionID string) {
+// setSessionCookie sets the session cookie; a negative maxAge removes it
+func (sm *SessionManager) setSessionCookie(w http.ResponseWriter, value string, maxAge int) {
+        w.Header().Add("Set-Cookie", sm.sessionCookie(value, maxAge))
+}
+
+// sessionCookie builds the Set-Cookie header of the session cookie
+func (sm *SessionManager) sessionCookie(value string, maxAge int) string {
         cookie := &http.Cookie{
-                Name:     "session_id",
-                Value:    sessionID,
-                Path:     "/",
-                MaxAge:   int(sm.maxAge.Seconds()),
+                Name:     sm.config.CookieName,
+                Value:    value,
+                Domain:   sm.config.Domain,
+                Path:     sm.config.Path,
+                MaxAge:   maxAge,
                 HttpOnly: true,
-                Secure:   false, // Set to true in production with HTTPS
-                SameSite: http.SameSiteLaxMode,
+                Secure:   sm.config.Secure,
+                SameSite: sm.config.SameSite,
+        }
+        
+        // http.Cookie only writes Partitioned from Go 1.23
+        header := cookie.String()
+        if sm.config.Partitioned {
+                header += "; Partitioned"
         }
-        http.SetCookie(w, cookie)
+        return header
 }
 
-// cleanup removes expired sessions
+// cleanup removes expired sessions from the store every CleanupInterval
+// until the manager is closed
 func (sm *SessionManager) cleanup() {
-        ticker := time.NewTicker(time.Hour)
+        ticker := time.NewTicker(sm.config.CleanupInterval)
         defer ticker.Stop()
         
-        for range ticker.C {
-                sm.mutex.Lock()
-                // In a real implementation, you'd track session creation times
-                // and remove expired sessions
-                sm.mutex.Unlock()
+        for {
+                select {
+                case <-sm.stop:
+                        return
+                case <-ticker.C:
+                        removed, err := sm.store.GC()
+                        sm.expired.Add(uint64(removed))
+                        if err != nil {
+                                log.Printf("Error removing expired sessions: %v", err)
+                        }
+                }
         }
 }
 
@@ -105,6 +438,7 @@ func (s *Session) Set(key string, value interface{}) {
         s.mutex.Lock()
         defer s.mutex.Unlock()
         s.data[key] = value
+        s.dirty = true
 }
 
 // Get retrieves a value from the session
@@ -137,6 +471,7 @@ func (s *Session) Delete(key string) {
         s.mutex.Lock()
         defer s.mutex.Unlock()
         delete(s.data, key)
+        s.dirty = true
 }
 
 // Clear removes all values from the session
@@ -144,6 +479,7 @@ func (s *Session) Clear() {
         s.mutex.Lock()
         defer s.mutex.Unlock()
         s.data = make(map[string]interface{})
+        s.dirty = true
 }
 
 // ID returns the session ID
@@ -151,6 +487,17 @@ func (s *Session) ID() string {
         return s.id
 }
 
+// Created returns when the session was started
+func (s *Session) Created() time.Time {
+        return s.created
+}
+
+// LastAccessed returns when the session was used before the current
+// request
+func (s *Session) LastAccessed() time.Time {
+        return s.accessed
+}
+
 // Has checks if a key exists in the session
 func (s *Session) Has(key string) bool {
         s.mutex.RLock()
@@ -159,6 +506,58 @@ func (s *Session) Has(key string) bool {
         return exists
 }
 
+// MarkModified flags the session to be saved, for changes made inside
+// stored values, such as appending to a stored slice, that Set does not see
+func (s *Session) MarkModified() {
+        s.mutex.Lock()
+        defer s.mutex.Unlock()
+        s.dirty = true
+}
+
+// Modified reports whether the session has changes to save
+func (s *Session) Modified() bool {
+        s.mutex.RLock()
+        defer s.mutex.RUnlock()
+        return s.dirty
+}
+
+// Regenerate moves the session to a new ID, keeping its data, and sends
+// the new cookie. Call it when a user logs in or gains privileges, so an
+// ID planted or seen before cannot be used to take over the session.
+func (s *Session) Regenerate() error {
+        s.mutex.Lock()
+        defer s.mutex.Unlock()
+        
+        if !s.isNew && s.manager.cookies == nil {
+                if err := s.manager.store.Delete(s.id); err != nil {
+                        return err
+                }
+        }
+        s.id = s.manager.generateSessionID()
+        s.created = time.Now()
+        s.isNew, s.dirty = true, true
+        return nil
+}
+
+// Destroy removes the session from the store and clears it, expiring its
+// cookie. Data set afterwards starts a new session.
+func (s *Session) Destroy() error {
+        s.mutex.Lock()
+        defer s.mutex.Unlock()
+        
+        if !s.isNew && s.manager.cookies == nil {
+                if err := s.manager.store.Delete(s.id); err != nil {
+                        return err
+                }
+        }
+        s.expireCookie = s.expireCookie || !s.isNew
+        s.id = s.manager.generateSessionID()
+        s.data = make(map[string]interface{})
+        s.created = time.Now()
+        s.isNew, s.dirty = true, false
+        return nil
+}
+
 // Keys returns all keys in the session
 func (s *Session) Keys() []string {
         s.mutex.RLock()
@@ -171,10 +570,11 @@ func (s *Session) Keys() []string {
         return keys
 }
 
-// SessionMiddleware returns a middleware that provides session support
+// SessionMiddleware is kept for compatibility and does nothing: sessions
+// need no middleware, Context.Session loads the session on first use and
+// saves it before the response headers are sent.
 func SessionMiddleware() MiddlewareFunc {
-        return func(c *Context) bool {
-                // Session is already initialized in NewContext
-                return true
+        return func(c *Context) {
+                c.Next()
         }
 }
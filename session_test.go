@@ -0,0 +1,317 @@
+package smallapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingStore counts the calls made to a store
+type countingStore struct {
+	SessionStore
+	loads, saves, touches atomic.Int64
+}
+
+func (s *countingStore) Load(id string) (*SessionRecord, error) {
+	s.loads.Add(1)
+	return s.SessionStore.Load(id)
+}
+
+func (s *countingStore) Save(id string, record *SessionRecord) error {
+	s.saves.Add(1)
+	return s.SessionStore.Save(id, record)
+}
+
+func (s *countingStore) Touch(id string, accessed, expiry time.Time) error {
+	s.touches.Add(1)
+	return s.SessionStore.Touch(id, accessed, expiry)
+}
+
+// calls returns the number of loads, saves and touches since the last call
+func (s *countingStore) calls() [3]int64 {
+	return [3]int64{s.loads.Swap(0), s.saves.Swap(0), s.touches.Swap(0)}
+}
+
+func TestSessionLazy(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.css"), []byte("body{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &countingStore{SessionStore: NewMemoryStore()}
+	app := New().SessionStore(store)
+	app.Static("/static/", dir)
+	app.Get("/plain", func(c *Context) { c.String("plain") })
+	app.Get("/read", func(c *Context) {
+		user, _ := c.Session().Get("user").(string)
+		c.String(user)
+	})
+	app.Get("/write", func(c *Context) {
+		c.Session().Set("user", "alice")
+		c.String("ok")
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/write", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("/write set %d cookies, want 1", len(cookies))
+	}
+	if calls := store.calls(); calls != [3]int64{0, 1, 0} {
+		t.Errorf("/write: loads, saves, touches = %v, want [0 1 0]", calls)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		cookie bool
+		body   string
+		calls  [3]int64 // loads, saves, touches
+	}{
+		{"plain", "/plain", true, "plain", [3]int64{}},
+		{"static file", "/static/app.css", true, "body{}", [3]int64{}},
+		{"read without session", "/read", false, "", [3]int64{}},
+		{"read", "/read", true, "alice", [3]int64{1, 0, 1}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		if tt.cookie {
+			r.AddCookie(&http.Cookie{Name: cookies[0].Name, Value: cookies[0].Value})
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		if body := w.Body.String(); body != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, body, tt.body)
+		}
+		if cookie := w.Header().Get("Set-Cookie"); cookie != "" {
+			t.Errorf("%s: Set-Cookie = %q", tt.name, cookie)
+		}
+		if calls := store.calls(); calls != tt.calls {
+			t.Errorf("%s: loads, saves, touches = %v, want %v", tt.name, calls, tt.calls)
+		}
+	}
+}
+
+func TestSessionCookieAttributes(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  SessionConfig
+		want    []string
+		notWant []string
+	}{
+		{"defaults", SessionConfig{},
+			[]string{"session_id=", "Path=/", "HttpOnly", "SameSite=Lax"}, []string{"Secure", "Domain", "Partitioned"}},
+		{"host prefix", SessionConfig{HostPrefix: true},
+			[]string{"__Host-session_id=", "Path=/", "Secure"}, []string{"Domain"}},
+		{"host prefix named", SessionConfig{HostPrefix: true, CookieName: "__Host-sid"},
+			[]string{"__Host-sid="}, []string{"__Host-__Host-"}},
+		{"scoped", SessionConfig{CookieName: "sid", Domain: "example.com", Path: "/app"},
+			[]string{"sid=", "Domain=example.com", "Path=/app"}, nil},
+		{"cross-site", SessionConfig{SameSite: http.SameSiteNoneMode, Secure: true},
+			[]string{"SameSite=None", "Secure"}, nil},
+		{"partitioned", SessionConfig{Partitioned: true, SameSite: http.SameSiteNoneMode, Secure: true},
+			[]string{"Secure", "; Partitioned"}, nil},
+	}
+
+	for _, tt := range tests {
+		app := New().ConfigureSessions(tt.config)
+		app.Get("/", func(c *Context) {
+			c.Session().Set("user", "alice")
+		})
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		cookie := w.Header().Get("Set-Cookie")
+		for _, s := range tt.want {
+			if !strings.Contains(cookie, s) {
+				t.Errorf("%s: %q lacks %q", tt.name, cookie, s)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(cookie, s) {
+				t.Errorf("%s: %q has %q", tt.name, cookie, s)
+			}
+		}
+	}
+}
+
+func TestSessionConfigRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		config SessionConfig
+	}{
+		{"SameSite=None without Secure", SessionConfig{SameSite: http.SameSiteNoneMode}},
+		{"Partitioned without Secure", SessionConfig{Partitioned: true}},
+		{"host prefix with a domain", SessionConfig{HostPrefix: true, Domain: "example.com"}},
+		{"host prefix with a path", SessionConfig{HostPrefix: true, Path: "/app"}},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: accepted", tt.name)
+				}
+			}()
+			New().ConfigureSessions(tt.config)
+		}()
+	}
+}
+
+// sessionApp serves requests to an application logging users into
+// sessions kept in store
+type sessionApp struct {
+	*App
+	t *testing.T
+}
+
+func newSessionApp(t *testing.T, store SessionStore, auth *AuthManager) sessionApp {
+	app := New().SessionStore(store)
+	app.Post("/visit", func(c *Context) {
+		c.Session().Set("cart", "apple")
+	})
+	app.Post("/login", func(c *Context) {
+		if _, err := auth.LoginSession(c, c.Query("user"), "secret"); err != nil {
+			c.Status(401).String(err.Error())
+		}
+	})
+	app.Get("/me", func(c *Context) {
+		c.String(c.Session().GetString(SessionUserKey) + " " + c.Session().GetString("cart"))
+	})
+	app.Post("/regenerate", func(c *Context) {
+		if err := c.Session().Regenerate(); err != nil {
+			c.Status(500).String(err.Error())
+		}
+	})
+	app.Post("/logout", func(c *Context) {
+		if err := auth.LogoutSession(c); err != nil {
+			c.Status(500).String(err.Error())
+		}
+	})
+	app.Post("/logout-all", func(c *Context) {
+		n, err := app.Sessions().DestroyAllFor(c.Session().GetString(SessionUserKey))
+		if err != nil {
+			c.Status(500).String(err.Error())
+			return
+		}
+		c.String(strconv.Itoa(n))
+	})
+	return sessionApp{app, t}
+}
+
+// do serves a request with the session cookie, if any
+func (a sessionApp) do(method, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	a.t.Helper()
+	r := httptest.NewRequest(method, path, nil)
+	if cookie != nil {
+		r.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if w.Code >= 400 {
+		a.t.Fatalf("%s %s: status %d: %s", method, path, w.Code, w.Body)
+	}
+	return w
+}
+
+// cookie returns the session cookie set by a response
+func (a sessionApp) cookie(w *httptest.ResponseRecorder) *http.Cookie {
+	a.t.Helper()
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		a.t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	return cookies[0]
+}
+
+// me returns who the session of cookie belongs to and what is in its cart
+func (a sessionApp) me(cookie *http.Cookie) string {
+	a.t.Helper()
+	return a.do("GET", "/me", cookie).Body.String()
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			auth := NewAuthManager()
+			alice, _ := auth.Register("alice", "alice@example.com", "secret")
+			bob, _ := auth.Register("bob", "bob@example.com", "secret")
+			app := newSessionApp(t, store, auth)
+
+			// Logging in moves the visitor's session to a new ID, keeping its data
+			visit := app.cookie(app.do("POST", "/visit", nil))
+			login := app.cookie(app.do("POST", "/login?user=alice", visit))
+			if login.Value == visit.Value {
+				t.Fatal("session ID kept on login")
+			}
+			if got := app.me(visit); got != " " {
+				t.Errorf("session before login = %q", got)
+			}
+			if got, want := app.me(login), alice.ID+" apple"; got != want {
+				t.Errorf("session after login = %q, want %q", got, want)
+			}
+
+			regenerated := app.cookie(app.do("POST", "/regenerate", login))
+			if got := app.me(login); got != " " {
+				t.Errorf("session before Regenerate = %q", got)
+			}
+			if got, want := app.me(regenerated), alice.ID+" apple"; got != want {
+				t.Errorf("session after Regenerate = %q, want %q", got, want)
+			}
+
+			// Logging out expires the cookie
+			other := app.cookie(app.do("POST", "/login?user=alice", nil))
+			expired := app.cookie(app.do("POST", "/logout", other))
+			if expired.Value != "" || expired.MaxAge >= 0 {
+				t.Errorf("cookie after Destroy = %+v", expired)
+			}
+			if got := app.me(other); got != " " {
+				t.Errorf("session after Destroy = %q", got)
+			}
+
+			// Logging out everywhere leaves the sessions of other users
+			laptop := app.cookie(app.do("POST", "/login?user=alice", nil))
+			phone := app.cookie(app.do("POST", "/login?user=bob", nil))
+			if got := app.do("POST", "/logout-all", laptop).Body.String(); got != "2" {
+				t.Errorf("DestroyAllFor removed %s sessions, want 2", got)
+			}
+			for _, cookie := range []*http.Cookie{regenerated, laptop} {
+				if got := app.me(cookie); got != " " {
+					t.Errorf("session after DestroyAllFor = %q", got)
+				}
+			}
+			if got, want := app.me(phone), bob.ID+" "; got != want {
+				t.Errorf("other user's session = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLoginSessionWrongPassword(t *testing.T) {
+	auth := NewAuthManager()
+	auth.Register("alice", "alice@example.com", "right")
+	app := New()
+	app.Post("/login", func(c *Context) {
+		if _, err := auth.LoginSession(c, "alice", "wrong"); err != nil {
+			c.Status(401).String(err.Error())
+		}
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("POST", "/login", nil))
+	if w.Code != 401 {
+		t.Errorf("status = %d, want 401", w.Code)
+	}
+	if cookie := w.Header().Get("Set-Cookie"); cookie != "" {
+		t.Errorf("Set-Cookie = %q", cookie)
+	}
+	if len(auth.sessions) != 0 {
+		t.Errorf("%d auth sessions", len(auth.sessions))
+	}
+}
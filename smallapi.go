@@ -9,6 +9,7 @@ import (
         "os"
         "os/signal"
         "path/filepath"
+        "strings"
         "syscall"
         "time"
 )
@@ -20,19 +21,57 @@ type App struct {
         templates  *TemplateEngine
         static     map[string]string
         sessions   *SessionManager
+        blueprints []*RouteGroup
+        wrappers   []func(http.Handler) http.Handler
+        handler    http.Handler // app wrapped by the wrappers, nil when there are none
+
+        beforeRequest     []HandlerFunc
+        afterRequest      []AfterRequestFunc
+        teardown          []TeardownFunc
+        errorHandlers     map[int]HandlerFunc
+        errorTypeHandlers []errorTypeHandler
+        problems          bool // render errors as RFC 9457 problem details
+        renderers         []Renderer
 }
 
-// MiddlewareFunc defines the middleware function signature
-type MiddlewareFunc func(*Context) bool
+// MiddlewareFunc defines the middleware function signature. Middleware
+// wraps the rest of the chain: it continues the request by calling c.Next()
+// and can act on the response once Next returns. Not calling Next stops
+// the request.
+//
+//	func Timer(c *smallapi.Context) {
+//	        start := time.Now()
+//	        c.Next()
+//	        log.Printf("%s took %v", c.Path(), time.Since(start))
+//	}
+type MiddlewareFunc func(*Context)
+
+// BoolMiddleware adapts middleware written in the older style, which
+// returns true to continue the request and false to stop it
+func BoolMiddleware(middleware func(*Context) bool) MiddlewareFunc {
+        return func(c *Context) {
+                if middleware(c) {
+                        c.Next()
+                }
+        }
+}
 
 // New creates a new SmallAPI application
 func New() *App {
-        return &App{
+        app := &App{
                 router:    NewRouter(),
                 templates: NewTemplateEngine(),
                 static:    make(map[string]string),
                 sessions:  NewSessionManager(),
+
+                errorHandlers: make(map[int]HandlerFunc),
+                renderers:     defaultRenderers(),
         }
+
+        // Make url_for available to templates loaded later
+        app.templates.AddFunc("url_for", app.templateURLFor)
+
+        return app
 }
 
 // Use adds middleware to the application
@@ -41,50 +80,77 @@ func (a *App) Use(middleware MiddlewareFunc) *App {
         return a
 }
 
-// Get adds a GET route
-func (a *App) Get(path string, handler HandlerFunc) *App {
-        a.router.Add("GET", path, handler)
+// Get adds a GET route, optionally named for URLFor
+func (a *App) Get(path string, handler HandlerFunc, name ...string) *App {
+        a.handle("GET", path, handler, name)
         return a
 }
 
 // Post adds a POST route
-func (a *App) Post(path string, handler HandlerFunc) *App {
-        a.router.Add("POST", path, handler)
+func (a *App) Post(path string, handler HandlerFunc, name ...string) *App {
+        a.handle("POST", path, handler, name)
         return a
 }
 
 // Put adds a PUT route
-func (a *App) Put(path string, handler HandlerFunc) *App {
-        a.router.Add("PUT", path, handler)
+func (a *App) Put(path string, handler HandlerFunc, name ...string) *App {
+        a.handle("PUT", path, handler, name)
         return a
 }
 
 // Delete adds a DELETE route
-func (a *App) Delete(path string, handler HandlerFunc) *App {
-        a.router.Add("DELETE", path, handler)
+func (a *App) Delete(path string, handler HandlerFunc, name ...string) *App {
+        a.handle("DELETE", path, handler, name)
         return a
 }
 
 // Patch adds a PATCH route
-func (a *App) Patch(path string, handler HandlerFunc) *App {
-        a.router.Add("PATCH", path, handler)
+func (a *App) Patch(path string, handler HandlerFunc, name ...string) *App {
+        a.handle("PATCH", path, handler, name)
         return a
 }
 
 // Options adds an OPTIONS route
-func (a *App) Options(path string, handler HandlerFunc) *App {
-        a.router.Add("OPTIONS", path, handler)
+func (a *App) Options(path string, handler HandlerFunc, name ...string) *App {
+        a.handle("OPTIONS", path, handler, name)
         return a
 }
 
 // Route adds a route for multiple HTTP methods
-func (a *App) Route(methods []string, path string, handler HandlerFunc) *App {
+func (a *App) Route(methods []string, path string, handler HandlerFunc, name ...string) *App {
         for _, method := range methods {
-                a.router.Add(method, path, handler)
+                a.handle(method, path, handler, name)
+        }
+        return a
+}
+
+// handle registers a route with an optional name for URLFor
+func (a *App) handle(method, path string, handler HandlerFunc, name []string) *Route {
+        return a.handleHost("", method, path, handler, name)
+}
+
+// handleHost registers a route bound to a host pattern ("" for any host)
+func (a *App) handleHost(host, method, path string, handler HandlerFunc, name []string) *Route {
+        route := a.router.AddHost(host, method, path, handler)
+        if len(name) > 0 && name[0] != "" {
+                a.router.Name(route, name[0])
         }
+        return route
+}
+
+// StrictRoutes makes route registration panic on duplicate or shadowed
+// routes, see Router.Conflicts, instead of logging a warning
+func (a *App) StrictRoutes(strict bool) *App {
+        a.router.SetStrict(strict)
         return a
 }
 
+// PrintRoutes prints a table of the registered routes, including the
+// application and group middleware each one runs
+func (a *App) PrintRoutes() {
+        a.router.WriteRoutes(os.Stdout, a.middleware)
+}
+
 // Static serves static files from a directory
 func (a *App) Static(urlPath, dirPath string) *App {
         a.static[urlPath] = dirPath
@@ -98,6 +164,15 @@ func (a *App) Templates(dir string) error {
 
 // ServeHTTP implements the http.Handler interface
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+        if a.handler != nil {
+                a.handler.ServeHTTP(w, r)
+                return
+        }
+        a.serve(w, r)
+}
+
+// serve handles a request once any standard middleware added with Wrap has run
+func (a *App) serve(w http.ResponseWriter, r *http.Request) {
         // Create context
         ctx := NewContext(w, r, a, a.sessions)
 
@@ -112,34 +187,113 @@ func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
                 }
         }
 
-        // Run middleware
-        for _, middleware := range a.middleware {
-                if !middleware(ctx) {
-                        return // Middleware stopped the request
+        // Recover from panics anywhere in the chain, then tear down
+        defer func() {
+                if rec := recover(); rec != nil {
+                        log.Printf("Panic in handler: %v", rec)
+                        err := &PanicError{Value: rec}
+                        if !ctx.Written() {
+                                a.renderError(ctx, ctx.route, 500, err)
+                        } else {
+                                ctx.err = err
+                        }
+                }
+
+                // Send the status set with c.Status when nothing was written
+                if !ctx.Written() {
+                        ctx.writer.WriteHeader(ctx.statusCode)
+                }
+
+                // Save session changes made after the headers were sent
+                if ctx.session != nil && ctx.session.Modified() {
+                        a.sessions.saveLate(ctx.session)
+                }
+
+                a.runTeardown(ctx)
+        }()
+
+        // Run the middleware, which ends by routing the request
+        ctx.handlers = make([]MiddlewareFunc, 0, len(a.middleware)+1)
+        ctx.handlers = append(ctx.handlers, a.middleware...)
+        ctx.handlers = append(ctx.handlers, a.dispatch)
+        ctx.Next()
+}
+
+// dispatch is the last application middleware: it finds the route,
+// continues the chain with the route's group middleware and its handler
+// and runs the request hooks around them
+func (a *App) dispatch(ctx *Context) {
+        r := ctx.Request
+        route, params := a.router.Lookup(r.Host, r.Method, r.URL.Path)
+
+        // Serve HEAD from the GET handler, without a body
+        if route == nil && r.Method == "HEAD" {
+                route, params = a.router.Lookup(r.Host, "GET", r.URL.Path)
+                if route != nil {
+                        ctx.Response = headResponseWriter{ctx.Response}
                 }
         }
 
-        // Find and execute route handler
-        handler, params := a.router.Match(r.Method, r.URL.Path)
-        if handler == nil {
-                ctx.Status(404).JSON(map[string]string{"error": "Not Found"})
-                return
+        if route == nil {
+                ctx.handlers = append(ctx.handlers, a.noRoute)
+        } else {
+                // Set route parameters
+                ctx.route = route
+                ctx.params = params
+
+                // Run the middleware of the route's group, then the handler
+                ctx.handlers = append(ctx.handlers, route.Middleware()...)
+                ctx.handlers = append(ctx.handlers, MiddlewareFunc(route.Handler))
+        }
+
+        a.runRequestHooks(ctx)
+}
+
+// noRoute answers requests that match no route with 404 or 405, or with
+// the allowed methods for OPTIONS
+func (a *App) noRoute(ctx *Context) {
+        r := ctx.Request
+        allowed := a.router.Allowed(r.Host, r.URL.Path)
+        switch {
+        case len(allowed) == 0:
+                a.renderError(ctx, nil, 404, nil)
+        case r.Method == "OPTIONS":
+                // Answer OPTIONS automatically unless a route handles it
+                ctx.Header("Allow", strings.Join(allowed, ", "))
+                ctx.Status(204)
+                ctx.Response.WriteHeader(204)
+        default:
+                ctx.Header("Allow", strings.Join(allowed, ", "))
+                a.renderError(ctx, nil, 405, nil)
         }
+}
 
-        // Set route parameters
-        ctx.params = params
+// renderError writes an error response with the status, using in order a
+// blueprint error handler for the status, an application handler for the
+// error, an application handler for the status, or the default body
+func (a *App) renderError(ctx *Context, route *Route, status int, err error) {
+        ctx.err = err
+        ctx.Status(status)
+        if handler := a.blueprintErrorHandler(route, ctx.Path(), status); handler != nil {
+                handler(ctx)
+                return
+        }
+        if handler := a.errorHandler(status, err); handler != nil {
+                handler(ctx)
+                return
+        }
+        ctx.sendError(status, err)
+}
 
-        // Execute handler with panic recovery
-        defer func() {
-                if r := recover(); r != nil {
-                        log.Printf("Panic in handler: %v", r)
-                        if !ctx.written {
-                                ctx.Status(500).JSON(map[string]string{"error": "Internal Server Error"})
-                        }
-                }
-        }()
+// headResponseWriter discards the body written by a GET handler serving a HEAD request
+type headResponseWriter struct {
+        http.ResponseWriter
+}
 
-        handler(ctx)
+// Write discards the body while reporting it as written
+func (w headResponseWriter) Write(b []byte) (int, error) {
+        w.ResponseWriter.WriteHeader(http.StatusOK)
+        return len(b), nil
 }
 
 // Run starts the HTTP server
@@ -173,6 +327,7 @@ func (a *App) Run(addr string) error {
         if err := server.Shutdown(ctx); err != nil {
                 log.Fatalf("Server forced to shutdown: %v", err)
         }
+        a.sessions.Close()
 
         fmt.Println("✅ Server stopped gracefully")
         return nil
@@ -182,6 +337,9 @@ func (a *App) Run(addr string) error {
 func (a *App) RunDev(addr string) error {
         fmt.Printf("🔥 SmallAPI server starting in DEV mode on %s\n", addr)
         fmt.Println("📁 Watching for file changes...")
+        fmt.Println()
+        a.PrintRoutes()
+        fmt.Println()
         
         // For now, just run normally - hot reload would require file watching
         // In a real implementation, you'd add file system watchers here
@@ -196,39 +354,90 @@ func (a *App) Group(prefix string) *RouteGroup {
         }
 }
 
-// RouteGroup represents a group of routes with a common prefix
+// RouteGroup represents a group of routes with a common prefix and its own
+// middleware stack. Groups can be nested with Group.
 type RouteGroup struct {
-        app    *App
-        prefix string
+        app        *App
+        parent     *RouteGroup
+        prefix     string
+        host       string // host pattern the group's routes are bound to, if any
+        middleware []MiddlewareFunc
+        blueprint  *Blueprint // set on the group a blueprint is registered as
+}
+
+// Group creates a nested group whose prefix and middleware extend this one
+func (g *RouteGroup) Group(prefix string) *RouteGroup {
+        return &RouteGroup{
+                app:    g.app,
+                parent: g,
+                prefix: g.prefix + prefix,
+                host:   g.host,
+        }
 }
 
 // Get adds a GET route to the group
-func (g *RouteGroup) Get(path string, handler HandlerFunc) *RouteGroup {
-        g.app.Get(g.prefix+path, handler)
+func (g *RouteGroup) Get(path string, handler HandlerFunc, name ...string) *RouteGroup {
+        g.handle("GET", path, handler, name)
         return g
 }
 
 // Post adds a POST route to the group
-func (g *RouteGroup) Post(path string, handler HandlerFunc) *RouteGroup {
-        g.app.Post(g.prefix+path, handler)
+func (g *RouteGroup) Post(path string, handler HandlerFunc, name ...string) *RouteGroup {
+        g.handle("POST", path, handler, name)
         return g
 }
 
 // Put adds a PUT route to the group
-func (g *RouteGroup) Put(path string, handler HandlerFunc) *RouteGroup {
-        g.app.Put(g.prefix+path, handler)
+func (g *RouteGroup) Put(path string, handler HandlerFunc, name ...string) *RouteGroup {
+        g.handle("PUT", path, handler, name)
         return g
 }
 
 // Delete adds a DELETE route to the group
-func (g *RouteGroup) Delete(path string, handler HandlerFunc) *RouteGroup {
-        g.app.Delete(g.prefix+path, handler)
+func (g *RouteGroup) Delete(path string, handler HandlerFunc, name ...string) *RouteGroup {
+        g.handle("DELETE", path, handler, name)
         return g
 }
 
-// Use adds middleware to the group
+// Patch adds a PATCH route to the group
+func (g *RouteGroup) Patch(path string, handler HandlerFunc, name ...string) *RouteGroup {
+        g.handle("PATCH", path, handler, name)
+        return g
+}
+
+// Options adds an OPTIONS route to the group
+func (g *RouteGroup) Options(path string, handler HandlerFunc, name ...string) *RouteGroup {
+        g.handle("OPTIONS", path, handler, name)
+        return g
+}
+
+// Route adds a route for multiple HTTP methods to the group
+func (g *RouteGroup) Route(methods []string, path string, handler HandlerFunc, name ...string) *RouteGroup {
+        for _, method := range methods {
+                g.handle(method, path, handler, name)
+        }
+        return g
+}
+
+// Use adds middleware to the group. It runs after the application
+// middleware, and only for requests matching a route of this group or
+// one of its nested groups.
 func (g *RouteGroup) Use(middleware MiddlewareFunc) *RouteGroup {
-        // In a real implementation, you'd track group-specific middleware
-        g.app.Use(middleware)
+        g.middleware = append(g.middleware, middleware)
         return g
 }
+
+// handle registers a route under the group prefix
+func (g *RouteGroup) handle(method, path string, handler HandlerFunc, name []string) *Route {
+        route := g.app.handleHost(g.host, method, g.prefix+path, handler, name)
+        route.group = g
+        return route
+}
+
+// chain returns the middleware of the group and its parents, outermost first
+func (g *RouteGroup) chain() []MiddlewareFunc {
+        if g.parent == nil {
+                return g.middleware
+        }
+        return append(append([]MiddlewareFunc(nil), g.parent.chain()...), g.middleware...)
+}
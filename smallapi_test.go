@@ -0,0 +1,71 @@
+package smallapi
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeMethods(t *testing.T) {
+	app := New()
+	app.Get("/users", func(c *Context) { c.String("users") })
+	app.Post("/users", func(c *Context) { c.Status(201).String("created") })
+	app.Get("/created", func(c *Context) { c.Status(201).String("created") })
+	app.Get("/accepted", func(c *Context) { c.Status(202) })
+	app.Get("/custom", func(c *Context) { c.String("custom") })
+	app.Options("/custom", func(c *Context) {
+		c.Header("Allow", "GET")
+		c.String("options")
+	})
+
+	tests := []struct {
+		method, path string
+		status       int
+		allow        string
+		body         string
+	}{
+		{"GET", "/users", 200, "", "users"},
+		{"POST", "/users", 201, "", "created"},
+		{"DELETE", "/users", 405, "GET, HEAD, OPTIONS, POST", ""},
+		{"PUT", "/missing", 404, "", ""},
+		{"HEAD", "/users", 200, "", ""},
+		{"HEAD", "/created", 201, "", ""},
+		{"HEAD", "/accepted", 202, "", ""},
+		{"HEAD", "/missing", 404, "", ""},
+		{"OPTIONS", "/users", 204, "GET, HEAD, OPTIONS, POST", ""},
+		{"OPTIONS", "/missing", 404, "", ""},
+		{"OPTIONS", "/custom", 200, "GET", "options"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, w.Code, tt.status)
+		}
+		if allow := w.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.path, allow, tt.allow)
+		}
+		if body := w.Body.String(); tt.status < 400 && body != tt.body {
+			t.Errorf("%s %s: body = %q, want %q", tt.method, tt.path, body, tt.body)
+		}
+	}
+}
+
+func TestServeOptionsCORS(t *testing.T) {
+	app := New()
+	app.Use(CORS())
+	app.Get("/users", func(c *Context) { c.String("users") })
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/users", nil))
+	if w.Code != 204 {
+		t.Errorf("status = %d, want 204", w.Code)
+	}
+	if methods := w.Header().Get("Access-Control-Allow-Methods"); methods == "" {
+		t.Error("no Access-Control-Allow-Methods")
+	}
+	// The preflight is answered by CORS before routing
+	if allow := w.Header().Get("Allow"); allow != "" {
+		t.Errorf("Allow = %q, want none", allow)
+	}
+}
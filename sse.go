@@ -0,0 +1,274 @@
+package smallapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a server-sent event
+type Event struct {
+	ID    string        // sent back by the browser as Last-Event-ID on reconnect
+	Event string        // event type, "message" in the browser when empty
+	Data  string        // may span several lines
+	Retry time.Duration // how long the browser waits before reconnecting
+}
+
+// SSEConfig configures an event stream
+type SSEConfig struct {
+	KeepAlive time.Duration // interval of keep-alive comments, 15s by default, negative to disable
+	Buffer    EventBuffer   // replays missed events to reconnecting clients
+}
+
+// defaultKeepAlive keeps idle streams open through proxies that close
+// silent connections
+const defaultKeepAlive = 15 * time.Second
+
+// EventStream sends server-sent events to one client
+type EventStream struct {
+	ctx         context.Context
+	w           http.ResponseWriter
+	flusher     http.Flusher
+	lastEventID string
+
+	mu  sync.Mutex
+	err error // first write error, after which nothing more is sent
+}
+
+// SSE streams server-sent events to the client. The handler sends events
+// until it returns or the client disconnects, which closes stream.Done().
+// Keep-alive comments are sent while the stream is idle. With a Buffer,
+// events the client missed since its Last-Event-ID are sent first.
+//
+//	app.Get("/events", func(c *smallapi.Context) {
+//	        c.SSE(func(stream *smallapi.EventStream) {
+//	                for {
+//	                        select {
+//	                        case price := <-prices:
+//	                                stream.Send(smallapi.Event{Event: "price", Data: price})
+//	                        case <-stream.Done():
+//	                                return
+//	                        }
+//	                }
+//	        })
+//	})
+func (c *Context) SSE(handler func(stream *EventStream), config ...SSEConfig) error {
+	var cfg SSEConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.KeepAlive == 0 {
+		cfg.KeepAlive = defaultKeepAlive
+	}
+
+	flusher, ok := c.Response.(http.Flusher)
+	if !ok || !c.writer.canFlush() {
+		return NewHTTPError(http.StatusInternalServerError, "").Wrap(errors.New("sse: response writer cannot flush, streams cannot run under Timeout"))
+	}
+
+	header := c.Response.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no") // keep nginx from buffering the stream
+	header.Del("Content-Length")
+	c.Response.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	stream := &EventStream{
+		ctx:         c.Request.Context(),
+		w:           c.Response,
+		flusher:     flusher,
+		lastEventID: c.Request.Header.Get("Last-Event-ID"),
+	}
+
+	if cfg.Buffer != nil && stream.lastEventID != "" {
+		for _, event := range cfg.Buffer.Since(stream.lastEventID) {
+			if stream.Send(event) != nil {
+				return nil
+			}
+		}
+	}
+
+	if cfg.KeepAlive > 0 {
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stream.keepAlive(cfg.KeepAlive, done)
+		}()
+		// The response must not be written once the handler has returned
+		defer wg.Wait()
+		defer close(done)
+	}
+
+	handler(stream)
+	return nil
+}
+
+// Send writes an event and flushes it to the client. It fails once the
+// client has disconnected.
+func (s *EventStream) Send(event Event) error {
+	var b strings.Builder
+	if event.ID != "" {
+		b.WriteString("id: " + singleLine(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		b.WriteString("event: " + singleLine(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	// An event with only a retry field sets the delay without being dispatched
+	if event.Data != "" || event.ID != "" || event.Event != "" || event.Retry == 0 {
+		data := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(event.Data)
+		for _, line := range strings.Split(data, "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// Data sends an unnamed event with data
+func (s *EventStream) Data(data string) error {
+	return s.Send(Event{Data: data})
+}
+
+// JSON sends an event of the given type with v encoded as JSON
+func (s *EventStream) JSON(event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Send(Event{Event: event, Data: string(data)})
+}
+
+// Comment sends a comment, which browsers ignore
+func (s *EventStream) Comment(text string) error {
+	return s.write(": " + singleLine(text) + "\n\n")
+}
+
+// Done is closed when the client disconnects
+func (s *EventStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// LastEventID returns the ID of the last event the client received before
+// reconnecting, or "" on the first connection
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// write sends text and flushes it, serialising the handler and keep-alives
+func (s *EventStream) write(text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.ctx.Err(); err != nil {
+		s.err = err
+		return err
+	}
+	if _, err := fmt.Fprint(s.w, text); err != nil {
+		s.err = err
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// keepAlive sends a comment every interval until done is closed or the
+// client disconnects
+func (s *EventStream) keepAlive(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.Comment("keep-alive") != nil {
+				return
+			}
+		case <-done:
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// singleLine replaces line breaks, which would end a field early
+func singleLine(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(s)
+}
+
+// EventBuffer keeps recent events so clients that reconnect with a
+// Last-Event-ID can be sent the ones they missed. Implement it to share
+// events between servers, for instance in Redis.
+type EventBuffer interface {
+	// Add stores an event, giving it an ID if it has none, and returns it
+	Add(event Event) Event
+
+	// Since returns the events after the one with lastID, oldest first.
+	// If lastID is no longer stored, every stored event is returned.
+	Since(lastID string) []Event
+}
+
+// MemoryEventBuffer is an EventBuffer holding the latest events in memory
+type MemoryEventBuffer struct {
+	mu     sync.Mutex
+	events []Event
+	size   int
+	nextID uint64
+}
+
+// NewEventBuffer creates a buffer of the latest size events. Publish
+// events through it and pass it to SSE in SSEConfig.Buffer.
+//
+//	events := smallapi.NewEventBuffer(100)
+//	event := events.Add(smallapi.Event{Event: "price", Data: "42"})
+//	broadcast(event) // to the handlers streaming to connected clients
+func NewEventBuffer(size int) *MemoryEventBuffer {
+	return &MemoryEventBuffer{size: size}
+}
+
+// Add stores an event, numbering it if it has no ID, and drops the oldest
+// one when the buffer is full
+func (b *MemoryEventBuffer) Add(event Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	if event.ID == "" {
+		event.ID = strconv.FormatUint(b.nextID, 10)
+	}
+	b.events = append(b.events, event)
+	if len(b.events) > b.size {
+		b.events = append(b.events[:0:0], b.events[len(b.events)-b.size:]...)
+	}
+	return event
+}
+
+// Since returns the events after the one with lastID
+func (b *MemoryEventBuffer) Since(lastID string) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := 0
+	for i := len(b.events) - 1; i >= 0; i-- {
+		if b.events[i].ID == lastID {
+			start = i + 1
+			break
+		}
+	}
+	return append([]Event(nil), b.events[start:]...)
+}
@@ -0,0 +1,426 @@
+package smallapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSessionNotFound is returned by a SessionStore for sessions that do
+// not exist or have expired
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionRecord is what a SessionStore keeps of a session
+type SessionRecord struct {
+	Data     map[string]interface{}
+	UserID   string // the SessionUserKey value, "" when logged out
+	Created  time.Time
+	Accessed time.Time // when the session was last used
+	Expiry   time.Time // after which the store may drop the session
+}
+
+// SessionStore keeps session data between requests. Implementations must
+// be safe for concurrent use.
+type SessionStore interface {
+	// Load returns a session, or ErrSessionNotFound
+	Load(id string) (*SessionRecord, error)
+
+	// Save stores a session
+	Save(id string, record *SessionRecord) error
+
+	// Delete removes a session
+	Delete(id string) error
+
+	// DeleteUser removes the sessions whose UserID is userID and returns
+	// how many
+	DeleteUser(userID string) (int, error)
+
+	// Touch records an access to an unmodified session and moves its
+	// expiry
+	Touch(id string, accessed, expiry time.Time) error
+
+	// GC removes expired sessions and returns how many
+	GC() (int, error)
+
+	// Count returns the number of stored sessions
+	Count() (int, error)
+}
+
+func init() {
+	// Nested values decoded from JSON bodies are commonly stored in sessions
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// encodeSessionRecord serializes a session with encoding/gob, which keeps
+// the types of values. Values of your own types must be registered with
+// gob.Register to be stored outside memory.
+func encodeSessionRecord(record *SessionRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(record); err != nil {
+		return nil, fmt.Errorf("encoding session: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeSessionRecord reverses encodeSessionRecord
+func decodeSessionRecord(encoded []byte) (*SessionRecord, error) {
+	var record SessionRecord
+	if err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(&record); err != nil {
+		return nil, fmt.Errorf("decoding session: %w", err)
+	}
+	if record.Data == nil {
+		record.Data = make(map[string]interface{})
+	}
+	return &record, nil
+}
+
+// copyRecord makes a copy of a session with a shallow copy of its data, so
+// concurrent requests of one session do not share a map
+func copyRecord(record *SessionRecord) *SessionRecord {
+	copied := *record
+	copied.Data = make(map[string]interface{}, len(record.Data))
+	for key, value := range record.Data {
+		copied.Data[key] = value
+	}
+	return &copied
+}
+
+// MemoryStore keeps sessions in memory. They are lost on restart and not
+// shared between processes.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*SessionRecord
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]*SessionRecord)}
+}
+
+// Load returns a copy of a session
+func (s *MemoryStore) Load(id string) (*SessionRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.sessions[id]
+	if !ok || time.Now().After(record.Expiry) {
+		return nil, ErrSessionNotFound
+	}
+	return copyRecord(record), nil
+}
+
+// Save stores a copy of a session
+func (s *MemoryStore) Save(id string, record *SessionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = copyRecord(record)
+	return nil
+}
+
+// Delete removes a session
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// DeleteUser removes the sessions of a user
+func (s *MemoryStore) DeleteUser(userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, record := range s.sessions {
+		if record.UserID == userID {
+			delete(s.sessions, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Touch records an access to a session
+func (s *MemoryStore) Touch(id string, accessed, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	record.Accessed, record.Expiry = accessed, expiry
+	return nil
+}
+
+// GC removes expired sessions
+func (s *MemoryStore) GC() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now, removed := time.Now(), 0
+	for id, record := range s.sessions {
+		if now.After(record.Expiry) {
+			delete(s.sessions, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Count returns the number of sessions held
+func (s *MemoryStore) Count() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.sessions), nil
+}
+
+// FileStore keeps each session in a file of a directory. Files are named
+// by a hash of the session ID, so IDs sent by clients never form paths.
+type FileStore struct {
+	dir string
+}
+
+// fileHeaderSize is the length of the expiry and access time that start
+// each session file, so they can be read and touched on their own. The
+// length and bytes of the user ID follow, so the sessions of a user can be
+// found without decoding them.
+const fileHeaderSize = 16
+
+// NewFileStore creates a store in dir, creating the directory if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns the file of a session
+func (s *FileStore) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(s.dir, "sess_"+hex.EncodeToString(sum[:]))
+}
+
+// Load reads a session file
+func (s *FileStore) Load(id string) (*SessionRecord, error) {
+	content, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(content) < fileHeaderSize || expired(content) {
+		return nil, ErrSessionNotFound
+	}
+	_, encoded, ok := splitFileUser(content[fileHeaderSize:])
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	record, err := decodeSessionRecord(encoded)
+	if err != nil {
+		return nil, err
+	}
+	record.Expiry, record.Accessed = readFileHeader(content)
+	return record, nil
+}
+
+// Save writes a session file, through a temporary file so readers never
+// see it half written
+func (s *FileStore) Save(id string, record *SessionRecord) error {
+	if len(record.UserID) > 0xffff {
+		return errors.New("filestore: user ID too long")
+	}
+	encoded, err := encodeSessionRecord(record)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "tmp_")
+	if err != nil {
+		return err
+	}
+	content := fileHeader(record.Accessed, record.Expiry)
+	content = binary.BigEndian.AppendUint16(content, uint16(len(record.UserID)))
+	content = append(append(content, record.UserID...), encoded...)
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(id))
+}
+
+// Delete removes a session file
+func (s *FileStore) Delete(id string) error {
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// DeleteUser reads the user ID in the header of every session file and
+// removes the files of a user
+func (s *FileStore) DeleteUser(userID string) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "sess_") {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		owner, ok := readFileUser(path)
+		if !ok {
+			continue // removed meanwhile, or truncated and left for GC
+		}
+		if owner == userID && os.Remove(path) == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Touch rewrites the header of a session file
+func (s *FileStore) Touch(id string, accessed, expiry time.Time) error {
+	f, err := os.OpenFile(s.path(id), os.O_WRONLY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(fileHeader(accessed, expiry), 0); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// GC removes expired session files, and temporary files left behind by
+// interrupted saves
+func (s *FileStore) GC() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		path := filepath.Join(s.dir, entry.Name())
+		switch {
+		case strings.HasPrefix(entry.Name(), "sess_"):
+			if fileExpired(path) && os.Remove(path) == nil {
+				removed++
+			}
+		case strings.HasPrefix(entry.Name(), "tmp_"):
+			if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > time.Hour {
+				os.Remove(path)
+			}
+		}
+	}
+	return removed, nil
+}
+
+// Count returns the number of session files
+func (s *FileStore) Count() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "sess_") {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// fileHeader encodes the expiry and access time of a session file
+func fileHeader(accessed, expiry time.Time) []byte {
+	header := make([]byte, fileHeaderSize)
+	binary.BigEndian.PutUint64(header[:8], uint64(expiry.UnixNano()))
+	binary.BigEndian.PutUint64(header[8:], uint64(accessed.UnixNano()))
+	return header
+}
+
+// readFileHeader decodes the expiry and access time of a session file
+func readFileHeader(b []byte) (expiry, accessed time.Time) {
+	expiry = time.Unix(0, int64(binary.BigEndian.Uint64(b[:8])))
+	accessed = time.Unix(0, int64(binary.BigEndian.Uint64(b[8:16])))
+	return expiry, accessed
+}
+
+// splitFileUser splits the user ID from the encoded record that follow
+// the header of a session file
+func splitFileUser(b []byte) (userID string, encoded []byte, ok bool) {
+	if len(b) < 2 {
+		return "", nil, false
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, false
+	}
+	return string(b[2 : 2+n]), b[2+n:], true
+}
+
+// readFileUser reads the user ID of the session file at path
+func readFileUser(path string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	header := make([]byte, fileHeaderSize+2)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return "", false
+	}
+	userID := make([]byte, binary.BigEndian.Uint16(header[fileHeaderSize:]))
+	if _, err := io.ReadFull(f, userID); err != nil {
+		return "", false
+	}
+	return string(userID), true
+}
+
+// fileExpired reports whether the session file at path has expired
+func fileExpired(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, fileHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return true // truncated
+	}
+	return expired(header)
+}
+
+// expired reports whether the expiry at the start of b has passed
+func expired(b []byte) bool {
+	return time.Now().UnixNano() > int64(binary.BigEndian.Uint64(b))
+}
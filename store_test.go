@@ -0,0 +1,302 @@
+package smallapi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testStores returns one store of each kind, in a temporary directory
+func testStores(t *testing.T) map[string]SessionStore {
+	t.Helper()
+	dir := t.TempDir()
+	files, err := NewFileStore(filepath.Join(dir, "files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kv, err := NewKVStore(filepath.Join(dir, "sessions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { kv.Close() })
+	return map[string]SessionStore{"memory": NewMemoryStore(), "file": files, "kv": kv}
+}
+
+// testRecord creates a session of userID that expires after ttl
+func testRecord(userID string, ttl time.Duration) *SessionRecord {
+	now := time.Now()
+	return &SessionRecord{
+		Data:     map[string]interface{}{"user": userID, "visits": 3},
+		UserID:   userID,
+		Created:  now,
+		Accessed: now,
+		Expiry:   now.Add(ttl),
+	}
+}
+
+func TestSessionStores(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			saved := map[string]*SessionRecord{
+				"a1": testRecord("alice", time.Hour),
+				"a2": testRecord("alice", time.Hour),
+				"b1": testRecord("bob", time.Hour),
+				"x1": testRecord("", -time.Second),
+			}
+			for id, record := range saved {
+				if err := store.Save(id, record); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			record, err := store.Load("b1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.UserID != "bob" || record.Data["user"] != "bob" || record.Data["visits"] != 3 {
+				t.Errorf("loaded %+v", record)
+			}
+			if !record.Expiry.Equal(saved["b1"].Expiry) {
+				t.Errorf("expiry = %v, want %v", record.Expiry, saved["b1"].Expiry)
+			}
+			if _, err := store.Load("x1"); !errors.Is(err, ErrSessionNotFound) {
+				t.Errorf("expired session: err = %v", err)
+			}
+			if _, err := store.Load("none"); !errors.Is(err, ErrSessionNotFound) {
+				t.Errorf("unknown session: err = %v", err)
+			}
+
+			expiry := time.Now().Add(2 * time.Hour)
+			if err := store.Touch("b1", time.Now(), expiry); err != nil {
+				t.Fatal(err)
+			}
+			if record, _ := store.Load("b1"); record == nil || !record.Expiry.Equal(expiry) {
+				t.Errorf("touched session: %+v, want expiry %v", record, expiry)
+			}
+
+			if n, err := store.DeleteUser("alice"); n != 2 || err != nil {
+				t.Errorf("DeleteUser = %d, %v, want 2", n, err)
+			}
+			if _, err := store.Load("a1"); !errors.Is(err, ErrSessionNotFound) {
+				t.Errorf("session of deleted user: err = %v", err)
+			}
+
+			if n, err := store.GC(); n != 1 || err != nil {
+				t.Errorf("GC = %d, %v, want 1", n, err)
+			}
+			if err := store.Delete("b1"); err != nil {
+				t.Fatal(err)
+			}
+			if n, err := store.Count(); n != 0 || err != nil {
+				t.Errorf("Count = %d, %v, want 0", n, err)
+			}
+		})
+	}
+}
+
+func TestFileStoreDeleteUserSkipsCorruptFiles(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a1", "a2", "b1"} {
+		owner := map[string]string{"a1": "alice", "a2": "alice", "b1": "bob"}[id]
+		if err := store.Save(id, testRecord(owner, time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A session whose data no longer decodes, e.g. of an unregistered type
+	content, err := os.ReadFile(store.path("a1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(content[len(content)-8:], "garbage!")
+	if err := os.WriteFile(store.path("a1"), content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// and one cut short
+	if err := os.WriteFile(store.path("t1"), []byte("short"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := store.DeleteUser("alice"); n != 2 || err != nil {
+		t.Errorf("DeleteUser = %d, %v, want 2", n, err)
+	}
+	if n, _ := store.Count(); n != 2 {
+		t.Errorf("Count = %d, want 2", n)
+	}
+}
+
+func TestKVStoreReplay(t *testing.T) {
+	tests := []struct {
+		name  string
+		cut   func(last, size int64) int64 // where the file ends, or -1 to append garbage
+		count int
+	}{
+		{"intact", func(last, size int64) int64 { return size }, 2},
+		{"torn checksum", func(last, size int64) int64 { return size - 3 }, 1},
+		{"torn data", func(last, size int64) int64 { return size - 40 }, 1},
+		{"torn header", func(last, size int64) int64 { return last + 10 }, 1},
+		{"garbage tail", func(last, size int64) int64 { return -1 }, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sessions.db")
+			store, err := NewKVStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.Save("a1", testRecord("alice", time.Hour))
+			last := store.size
+			store.Save("b1", testRecord("bob", time.Hour))
+			size := store.size
+			store.Close()
+
+			if cut := tt.cut(last, size); cut >= 0 {
+				os.Truncate(path, cut)
+			} else {
+				f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+				f.Write([]byte{kvPut, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+				f.Close()
+			}
+
+			store, err = NewKVStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			if n, _ := store.Count(); n != tt.count {
+				t.Fatalf("Count = %d, want %d", n, tt.count)
+			}
+			if record, err := store.Load("a1"); err != nil || record.UserID != "alice" {
+				t.Errorf("Load = %+v, %v", record, err)
+			}
+
+			// New records go after the last valid one
+			if err := store.Save("c1", testRecord("carol", time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			store.Close()
+			store, err = NewKVStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			if n, _ := store.Count(); n != tt.count+1 {
+				t.Errorf("Count after reopening = %d, want %d", n, tt.count+1)
+			}
+		})
+	}
+}
+
+func TestKVStoreReplayOperations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	store, err := NewKVStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry := time.Now().Add(3 * time.Hour)
+	store.Save("a1", testRecord("alice", time.Hour))
+	store.Save("a2", testRecord("alice", time.Hour))
+	store.Save("b1", testRecord("bob", time.Hour))
+	store.Save("b1", testRecord("bob", 2*time.Hour))
+	store.Touch("b1", time.Now(), expiry)
+	store.Delete("a2")
+	store.Close()
+
+	store, err = NewKVStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if n, _ := store.Count(); n != 2 {
+		t.Errorf("Count = %d, want 2", n)
+	}
+	if record, err := store.Load("b1"); err != nil || !record.Expiry.Equal(expiry) {
+		t.Errorf("Load = %+v, %v, want expiry %v", record, err, expiry)
+	}
+	if n, err := store.DeleteUser("alice"); n != 1 || err != nil {
+		t.Errorf("DeleteUser = %d, %v, want 1", n, err)
+	}
+}
+
+func TestKVStoreDeleteUserSkipsCorruptData(t *testing.T) {
+	store, err := NewKVStore(filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.Save("a1", testRecord("alice", time.Hour))
+	times := kvTimes{expiry: time.Now().Add(time.Hour).UnixNano()}
+	if err := store.append(kvPut, "a2", "alice", times, []byte("not gob"), true); err != nil {
+		t.Fatal(err)
+	}
+	store.Save("b1", testRecord("bob", time.Hour))
+
+	if _, err := store.Load("a2"); err == nil {
+		t.Error("corrupt session loaded")
+	}
+	if n, err := store.DeleteUser("alice"); n != 2 || err != nil {
+		t.Errorf("DeleteUser = %d, %v, want 2", n, err)
+	}
+	if n, _ := store.Count(); n != 1 {
+		t.Errorf("Count = %d, want 1", n)
+	}
+}
+
+func TestKVStoreCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	store, err := NewKVStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite a few sessions until most of the file is obsolete
+	record := testRecord("alice", time.Hour)
+	record.Data["padding"] = string(make([]byte, 4096))
+	for store.size < 2*kvMinCompact {
+		store.Save("a1", record)
+		store.Save("b1", testRecord("bob", time.Hour))
+	}
+	store.Save("x1", testRecord("", -time.Second))
+	before := store.size
+
+	if n, err := store.GC(); n != 1 || err != nil {
+		t.Fatalf("GC = %d, %v, want 1", n, err)
+	}
+	if store.size >= before/10 {
+		t.Errorf("size after compaction = %d, was %d", store.size, before)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Error(err)
+	} else if info.Size() != store.size {
+		t.Errorf("file size = %d, want %d", info.Size(), store.size)
+	}
+	if _, err := os.Stat(path + ".compact"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	// The compacted file is used and replays to the same sessions
+	store.Save("c1", testRecord("carol", time.Hour))
+	store.Close()
+	store, err = NewKVStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if n, _ := store.Count(); n != 3 {
+		t.Errorf("Count = %d, want 3", n)
+	}
+	loaded, err := store.Load("a1")
+	if err != nil || loaded.Data["padding"] != record.Data["padding"] {
+		t.Errorf("Load = %v, %v", loaded, err)
+	}
+	if n, err := store.DeleteUser("bob"); n != 1 || err != nil {
+		t.Errorf("DeleteUser = %d, %v, want 1", n, err)
+	}
+}
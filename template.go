@@ -26,7 +26,17 @@ func NewTemplateEngine() *TemplateEngine {
 // LoadDir loads all templates from a directory
 func (te *TemplateEngine) LoadDir(dir string) error {
         te.dir = dir
-        
+        return te.loadDir(dir, "")
+}
+
+// LoadNamespace loads all templates from a directory under a name prefix,
+// e.g. "login.html" in a blueprint named "auth" becomes "auth/login.html"
+func (te *TemplateEngine) LoadNamespace(dir, namespace string) error {
+        return te.loadDir(dir, namespace+"/")
+}
+
+// loadDir loads the .html files below dir, prefixing their names
+func (te *TemplateEngine) loadDir(dir, prefix string) error {
         // Walk through the directory and load all .html files
         err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
                 if err != nil {
@@ -49,7 +59,7 @@ func (te *TemplateEngine) LoadDir(dir string) error {
                         if err != nil {
                                 return err
                         }
-                        te.templates[name] = tmpl
+                        te.templates[prefix+name] = tmpl
                 }
                 
                 return nil
@@ -0,0 +1,329 @@
+package smallapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxFieldSize limits the plain fields of a streamed upload when there is
+// no total limit, as net/http does for non-file form values
+const maxFieldSize = 10 << 20
+
+// MultipartForm parses a multipart/form-data body, keeping up to 32 MB in
+// memory and the rest in temporary files, and returns it. Use Upload to
+// stream large files instead.
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if c.Request.MultipartForm == nil {
+		if err := c.parseForm("multipart/form-data"); err != nil {
+			return nil, err
+		}
+	}
+	return c.Request.MultipartForm, nil
+}
+
+// FormFile returns the first file uploaded in a multipart form field. A
+// missing file is a 400 *HTTPError wrapping http.ErrMissingFile.
+//
+//	file, err := c.FormFile("avatar")
+//	if err != nil {
+//	        return err
+//	}
+//	c.SaveFile(file, "./uploads/"+id+".png")
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	files := form.File[name]
+	if len(files) == 0 {
+		return nil, NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Missing file %s", name)).Wrap(http.ErrMissingFile)
+	}
+	return files[0], nil
+}
+
+// SaveFile copies an uploaded file to dst
+func (c *Context) SaveFile(file *multipart.FileHeader, dst string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// UploadConfig configures a streamed upload. Zero values mean no limit,
+// any content type, temporary files and no progress reports.
+type UploadConfig struct {
+	MaxFileSize  int64                // bytes per file
+	MaxTotalSize int64                // bytes for all files and fields together
+	AllowedTypes []string             // sniffed types such as "image/png" or "image/*"
+	Storage      UploadStorage        // where files are written, DirStorage{} by default
+	Progress     func(UploadProgress) // called as the body is read
+}
+
+// UploadProgress reports how much of an upload has been read
+type UploadProgress struct {
+	Field    string // form field of the file being read
+	Filename string
+	Read     int64 // bytes of all parts read so far
+	Total    int64 // Content-Length of the request, -1 if unknown
+}
+
+// UploadedFile describes a file stored by Upload
+type UploadedFile struct {
+	Field       string `json:"field"`
+	Filename    string `json:"filename"`     // name sent by the client, without directories
+	ContentType string `json:"content_type"` // sniffed from the content
+	Size        int64  `json:"size"`
+	Location    string `json:"-"` // where the storage put it, such as a file path
+}
+
+// UploadStorage stores uploaded files as they are streamed in
+type UploadStorage interface {
+	// Store writes the content of file and returns its location. file has
+	// every field but Size and Location set.
+	Store(file *UploadedFile, content io.Reader) (location string, err error)
+
+	// Remove deletes a stored file, when a later part of the upload fails
+	Remove(location string) error
+}
+
+// DirStorage stores uploads as files with random names in Dir, or in the
+// system temporary directory when Dir is empty
+type DirStorage struct {
+	Dir string
+}
+
+// safeExt matches file extensions worth keeping on stored files
+var safeExt = regexp.MustCompile(`^\.[A-Za-z0-9]{1,10}$`)
+
+// Store writes content to a new file in the directory
+func (s DirStorage) Store(file *UploadedFile, content io.Reader) (string, error) {
+	dir := s.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	} else if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(file.Filename)
+	if !safeExt.MatchString(ext) {
+		ext = ""
+	}
+	out, err := os.CreateTemp(dir, "upload-*"+ext)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(out, content); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// Remove deletes a stored file
+func (s DirStorage) Remove(location string) error {
+	return os.Remove(location)
+}
+
+// Upload streams the files of a multipart/form-data request to storage
+// without holding them in memory, enforcing the configured limits. Plain
+// fields become available through c.Form. If a limit is exceeded, files
+// already stored are removed and a 413 or 415 *HTTPError is returned.
+//
+//	files, err := c.Upload(smallapi.UploadConfig{
+//	        MaxFileSize:  10 << 20,
+//	        AllowedTypes: []string{"image/png", "image/jpeg"},
+//	        Storage:      smallapi.DirStorage{Dir: "./uploads"},
+//	})
+func (c *Context) Upload(config UploadConfig) ([]*UploadedFile, error) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, NewHTTPError(http.StatusBadRequest, "Expected a multipart/form-data body").Wrap(err)
+	}
+
+	storage := config.Storage
+	if storage == nil {
+		storage = DirStorage{}
+	}
+
+	var files []*UploadedFile
+	fail := func(err error) ([]*UploadedFile, error) {
+		for _, file := range files {
+			storage.Remove(file.Location)
+		}
+		return nil, err
+	}
+
+	counter := &uploadCounter{config: config, total: c.Request.ContentLength}
+	form := url.Values{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(NewHTTPError(http.StatusBadRequest, "Invalid multipart body").Wrap(err))
+		}
+
+		if part.FileName() == "" {
+			value, err := counter.readField(part)
+			if err != nil {
+				return fail(err)
+			}
+			form.Add(part.FormName(), value)
+			continue
+		}
+
+		file, err := counter.store(part, storage)
+		if err != nil {
+			return fail(err)
+		}
+		files = append(files, file)
+	}
+
+	c.form = form
+	return files, nil
+}
+
+// uploadCounter enforces the limits of an upload while its parts are read
+type uploadCounter struct {
+	config UploadConfig
+	total  int64
+	read   int64
+
+	// current part
+	part     *multipart.Part
+	partRead int64
+	isFile   bool
+}
+
+// Read reads from the current part, counting and limiting the bytes
+func (u *uploadCounter) Read(p []byte) (int, error) {
+	n, err := u.part.Read(p)
+	u.read += int64(n)
+	u.partRead += int64(n)
+
+	if u.config.MaxTotalSize > 0 && u.read > u.config.MaxTotalSize {
+		return n, NewHTTPError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Upload exceeds %d bytes", u.config.MaxTotalSize))
+	}
+	if u.isFile && u.config.MaxFileSize > 0 && u.partRead > u.config.MaxFileSize {
+		return n, NewHTTPError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("File %s exceeds %d bytes", u.part.FileName(), u.config.MaxFileSize))
+	}
+	if u.isFile && u.config.Progress != nil && n > 0 {
+		u.config.Progress(UploadProgress{
+			Field:    u.part.FormName(),
+			Filename: u.part.FileName(),
+			Read:     u.read,
+			Total:    u.total,
+		})
+	}
+	return n, err
+}
+
+// readField reads a plain form field
+func (u *uploadCounter) readField(part *multipart.Part) (string, error) {
+	u.part, u.partRead, u.isFile = part, 0, false
+
+	limit := int64(maxFieldSize)
+	if u.config.MaxTotalSize > 0 {
+		limit = u.config.MaxTotalSize - u.read
+	}
+	value, err := io.ReadAll(io.LimitReader(u, limit+1))
+	if err != nil {
+		return "", uploadError(err)
+	}
+	if int64(len(value)) > limit {
+		return "", NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Field %s is too large", part.FormName()))
+	}
+	return string(value), nil
+}
+
+// store sniffs the type of a file part and streams it to storage
+func (u *uploadCounter) store(part *multipart.Part, storage UploadStorage) (*UploadedFile, error) {
+	u.part, u.partRead, u.isFile = part, 0, true
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(u, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, uploadError(err)
+	}
+	head = head[:n]
+
+	file := &UploadedFile{
+		Field:       part.FormName(),
+		Filename:    filepath.Base(part.FileName()),
+		ContentType: sniffContentType(head),
+	}
+	if !typeAllowed(file.ContentType, u.config.AllowedTypes) {
+		return nil, NewHTTPError(http.StatusUnsupportedMediaType,
+			fmt.Sprintf("File type %s is not allowed", file.ContentType))
+	}
+
+	location, err := storage.Store(file, io.MultiReader(bytes.NewReader(head), u))
+	if err != nil {
+		return nil, uploadError(err)
+	}
+	file.Location = location
+	file.Size = u.partRead
+	return file, nil
+}
+
+// uploadError passes limit errors through and reports anything else as a
+// failure to read or store the upload
+func uploadError(err error) error {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	return NewHTTPError(http.StatusBadRequest, "Upload failed").Wrap(err)
+}
+
+// sniffContentType detects the media type of content, without parameters
+func sniffContentType(head []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return mediaType
+}
+
+// typeAllowed matches a media type against an allowlist that may contain
+// wildcards such as "image/*"; an empty list allows everything
+func typeAllowed(mediaType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mediaType || pattern == "*/*" ||
+			(strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package smallapi
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// URL builds the path of the named route, substituting and escaping params
+// and appending query. Optional parameters and catch-alls may be omitted.
+func (r *Router) URL(name string, params map[string]string, query url.Values) (string, error) {
+	route := r.names[name]
+	if route == nil {
+		return "", fmt.Errorf("route %s not found", name)
+	}
+
+	var b strings.Builder
+	for _, segment := range route.Pattern.Segments {
+		switch {
+		case segment.IsWildcard:
+			value := strings.Trim(params[segment.Name], "/")
+			if value == "" {
+				continue
+			}
+			// Keep the slashes of the captured remainder, escape the rest
+			parts := strings.Split(value, "/")
+			for i, part := range parts {
+				parts[i] = url.PathEscape(part)
+			}
+			b.WriteString("/")
+			b.WriteString(strings.Join(parts, "/"))
+		case segment.IsParam:
+			value := params[segment.Name]
+			if value == "" {
+				if segment.Optional {
+					continue
+				}
+				return "", fmt.Errorf("parameter %s is required by route %s", segment.Name, name)
+			}
+			if segment.match != nil && !segment.match(value) {
+				return "", fmt.Errorf("parameter %s=%q does not satisfy <%s> in route %s", segment.Name, value, segment.Constraint, name)
+			}
+			b.WriteString("/")
+			b.WriteString(url.PathEscape(value))
+		case segment.Value != "":
+			b.WriteString("/")
+			b.WriteString(segment.Value)
+		}
+	}
+
+	if b.Len() == 0 {
+		b.WriteString("/")
+	}
+	if len(query) > 0 {
+		b.WriteString("?")
+		b.WriteString(query.Encode())
+	}
+
+	return b.String(), nil
+}
+
+// URLFor builds the URL of a named route, similar to Flask's url_for
+//
+//	app.Get("/users/:id", showUser, "user")
+//	link, err := app.URLFor("user", map[string]string{"id": "42"}, nil) // "/users/42"
+func (a *App) URLFor(name string, params map[string]string, query url.Values) (string, error) {
+	return a.router.URL(name, params, query)
+}
+
+// URLFor builds the URL of a named route. Inside a blueprint, a name
+// starting with "." refers to a route of the same blueprint (".login").
+func (c *Context) URLFor(name string, params map[string]string, query url.Values) (string, error) {
+	if strings.HasPrefix(name, ".") && c.route != nil {
+		for g := c.route.group; g != nil; g = g.parent {
+			if g.blueprint != nil {
+				name = g.blueprint.Name + name
+				break
+			}
+		}
+	}
+	return c.app.URLFor(name, params, query)
+}
+
+// templateURLFor implements the url_for template function. Arguments after
+// the route name are key/value pairs; keys that are not route parameters
+// are added to the query string, as in Flask.
+//
+//	<a href="{{ url_for "user" "id" .ID "tab" "posts" }}">
+func (a *App) templateURLFor(name string, pairs ...interface{}) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("url_for %s: odd number of key/value arguments", name)
+	}
+
+	route := a.router.Named(name)
+	if route == nil {
+		return "", fmt.Errorf("route %s not found", name)
+	}
+	names := route.Pattern.ParamNames()
+
+	params := make(map[string]string)
+	query := url.Values{}
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return "", fmt.Errorf("url_for %s: argument %d must be a string key", name, i+1)
+		}
+		value := fmt.Sprint(pairs[i+1])
+		if containsString(names, key) {
+			params[key] = value
+		} else {
+			query.Add(key, value)
+		}
+	}
+
+	return a.router.URL(name, params, query)
+}
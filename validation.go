@@ -3,6 +3,8 @@ package smallapi
 import (
 	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -28,7 +30,33 @@ func NewValidator() *Validator {
 	}
 }
 
-// Validate validates a struct based on field tags
+// FieldError describes a field that failed validation
+type FieldError struct {
+	Field   string `json:"field"` // JSON name of the field
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error returns the message
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors is the error returned when fields fail validation.
+// Returned from a handler, it is answered with 422 and the field errors.
+type ValidationErrors []FieldError
+
+// Error joins the messages of the field errors
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate validates a struct based on field tags. Failures are returned
+// as ValidationErrors.
 func (c *Context) Validate(v interface{}) error {
 	return validateStruct(v)
 }
@@ -45,7 +73,7 @@ func validateStruct(v interface{}) error {
 	}
 	
 	typ := val.Type()
-	var errs []string
+	var errs ValidationErrors
 	
 	for i := 0; i < val.NumField(); i++ {
 		field := val.Field(i)
@@ -61,18 +89,34 @@ func validateStruct(v interface{}) error {
 		rules := parseValidationTag(tag)
 		
 		// Validate field
-		if err := validateField(field, fieldType.Name, rules); err != nil {
-			errs = append(errs, err.Error())
+		for _, rule := range rules {
+			if err := applyValidationRule(field, fieldType.Name, rule); err != nil {
+				errs = append(errs, FieldError{
+					Field:   jsonFieldName(fieldType),
+					Rule:    rule.Type,
+					Message: err.Error(),
+				})
+				break
+			}
 		}
 	}
 	
 	if len(errs) > 0 {
-		return errors.New(strings.Join(errs, "; "))
+		return errs
 	}
 	
 	return nil
 }
 
+// jsonFieldName returns the name a struct field has in JSON
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
 // parseValidationTag parses a validation tag into rules
 func parseValidationTag(tag string) []ValidationRule {
 	var rules []ValidationRule
@@ -101,16 +145,6 @@ func parseValidationTag(tag string) []ValidationRule {
 	return rules
 }
 
-// validateField validates a single field against rules
-func validateField(field reflect.Value, fieldName string, rules []ValidationRule) error {
-	for _, rule := range rules {
-		if err := applyValidationRule(field, fieldName, rule); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // applyValidationRule applies a single validation rule
 func applyValidationRule(field reflect.Value, fieldName string, rule ValidationRule) error {
 	switch rule.Type {
@@ -132,6 +166,10 @@ func applyValidationRule(field reflect.Value, fieldName string, rule ValidationR
 		return validateAlphaNum(field, fieldName)
 	case "regex":
 		return validateRegex(field, fieldName, rule.Value)
+	case "maxsize":
+		return validateMaxSize(field, fieldName, rule.Value)
+	case "mime":
+		return validateMime(field, fieldName, rule.Value)
 	default:
 		return fmt.Errorf("unknown validation rule: %s", rule.Type)
 	}
@@ -310,3 +348,79 @@ func validateRegex(field reflect.Value, fieldName, pattern string) error {
 	
 	return nil
 }
+
+// validateMaxSize checks the size of uploaded files, given in bytes or with
+// a KB, MB or GB suffix
+func validateMaxSize(field reflect.Value, fieldName, sizeStr string) error {
+	max, err := parseByteSize(sizeStr)
+	if err != nil {
+		return fmt.Errorf("invalid maxsize value: %s", sizeStr)
+	}
+
+	for _, file := range fieldFiles(field) {
+		if file.Size > max {
+			return fmt.Errorf("%s must be at most %s", fieldName, sizeStr)
+		}
+	}
+	return nil
+}
+
+// validateMime checks the sniffed content type of uploaded files against a
+// list separated by "|", such as "image/png|image/jpeg" or "image/*"
+func validateMime(field reflect.Value, fieldName, types string) error {
+	allowed := strings.Split(types, "|")
+	for _, file := range fieldFiles(field) {
+		src, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("%s could not be read", fieldName)
+		}
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(src, head)
+		src.Close()
+
+		if mediaType := sniffContentType(head[:n]); !typeAllowed(mediaType, allowed) {
+			return fmt.Errorf("%s must be of type %s, not %s", fieldName, strings.Join(allowed, " or "), mediaType)
+		}
+	}
+	return nil
+}
+
+// fileHeaderType is the type of uploaded file fields
+var fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+
+// fieldFiles returns the uploaded files in a *multipart.FileHeader or
+// []*multipart.FileHeader field
+func fieldFiles(field reflect.Value) []*multipart.FileHeader {
+	switch {
+	case field.Type() == fileHeaderType:
+		if file, _ := field.Interface().(*multipart.FileHeader); file != nil {
+			return []*multipart.FileHeader{file}
+		}
+	case field.Kind() == reflect.Slice && field.Type().Elem() == fileHeaderType:
+		files, _ := field.Interface().([]*multipart.FileHeader)
+		return files
+	}
+	return nil
+}
+
+// parseByteSize parses a size such as "512", "100KB" or "2MB"
+func parseByteSize(size string) (int64, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		bytes  int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(size, unit.suffix) {
+			size = strings.TrimSpace(strings.TrimSuffix(size, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n * multiplier, nil
+}